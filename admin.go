@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// adminUser 首次运行时创建的组织者账号，初始密码保存在数据目录下的 admin.key
+const adminUser = "admin"
+
+// maxUploadSize 管理后台上传 Excel 的大小上限
+const maxUploadSize = 16 << 20
+
+var (
+	// acceptingSubmissions 是否接收答题，暂停时不再下发题目和接收答卷，调用方需持有 mutex
+	acceptingSubmissions = true
+
+	// onQuestionsChange 通过网页后台更新题库后回调（由管理界面设置），参数为题目数量
+	onQuestionsChange func(n int)
+	// onAcceptingChange 暂停/恢复接收答题后回调（由管理界面设置）
+	onAcceptingChange func(on bool)
+
+	adminKeyOnce sync.Once
+	adminKey     string
+	adminKeyErr  error
+)
+
+// loadAdminPassword 读取 admin 账号的初始密码，不存在时随机生成并保存到数据目录
+func loadAdminPassword() (string, error) {
+	adminKeyOnce.Do(func() {
+		path := filepath.Join(dataDir, "admin.key")
+		if b, err := os.ReadFile(path); err == nil {
+			if adminKey = strings.TrimSpace(string(b)); adminKey != "" {
+				return
+			}
+		}
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			adminKeyErr = err
+			return
+		}
+		key := hex.EncodeToString(b)
+		if err := os.WriteFile(path, []byte(key), 0600); err != nil {
+			adminKeyErr = err
+			return
+		}
+		adminKey = key
+	})
+	return adminKey, adminKeyErr
+}
+
+// setAccepting 暂停或恢复接收答题
+func setAccepting(on bool) {
+	mutex.Lock()
+	acceptingSubmissions = on
+	mutex.Unlock()
+	if onAcceptingChange != nil {
+		onAcceptingChange(on)
+	}
+	if on {
+		log.Println("已恢复接收答题")
+	} else {
+		log.Println("已暂停接收答题")
+	}
+}
+
+// saveUpload 将上传的文件保存到数据目录下的 uploads 目录，返回保存位置
+func saveUpload(r *http.Request, field, name string) (string, error) {
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return "", fmt.Errorf("上传内容错误: %v", err)
+	}
+	f, _, err := r.FormFile(field)
+	if err != nil {
+		return "", fmt.Errorf("未选择文件")
+	}
+	defer f.Close()
+
+	dir := filepath.Join(dataDir, "uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	out, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, f); err != nil {
+		out.Close()
+		return "", err
+	}
+	return path, out.Close()
+}
+
+// adminStats 管理后台显示的统计数据
+type adminStats struct {
+	Accepting         bool           `json:"accepting"`
+	Status            QuizStatus     `json:"status"` // 按开放时间、手动暂停和服务状态计算的答题状态
+	ServerURL         string         `json:"server_url"`
+	Questions         int            `json:"questions"`
+	ResultsFile       string         `json:"results_file"`
+	SubmissionsToday  int            `json:"submissions_today"`
+	ParticipantsToday int            `json:"participants_today"`
+	AverageToday      int            `json:"average_today"` // 今日平均得分百分比
+	PrizesToday       map[string]int `json:"prizes_today"`
+	Inventory         []LevelStock   `json:"inventory"`
+}
+
+// collectAdminStatsLocked 汇总今天的答题情况和库存，调用方需持有 mutex
+func collectAdminStatsLocked() (adminStats, error) {
+	st := adminStats{
+		Accepting:   acceptingSubmissions,
+		Status:      attemptStatusLocked(),
+		ServerURL:   publicURLLocked(),
+		Questions:   len(questions),
+		PrizesToday: map[string]int{},
+		Inventory:   inventorySnapshotLocked(),
+	}
+	st.ResultsFile = resultStoreLocked().Location()
+	recs, err := recordsLocked()
+	if err != nil {
+		return st, err
+	}
+	people := map[string]bool{}
+	sum := 0
+	for _, r := range recs {
+		if !sameEventDay(r.Time) {
+			continue
+		}
+		st.SubmissionsToday++
+		people[r.Phone] = true
+		if r.Total > 0 {
+			sum += r.Score * 100 / r.Total
+		}
+	}
+	st.ParticipantsToday = len(people)
+	if st.SubmissionsToday > 0 {
+		st.AverageToday = sum / st.SubmissionsToday
+	}
+	st.PrizesToday = prizesIssuedToday(recs)
+	return st, nil
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError 输出 JSON 格式的错误 {"error": "..."}，页面统一从 error 字段读取说明
+func writeError(w http.ResponseWriter, msg string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// setupAdminHandlers 网页管理后台（/admin）及其接口，功能与桌面管理界面一致
+func setupAdminHandlers(mux *http.ServeMux) {
+	setupAuthHandlers(mux)
+
+	// 未登录时跳转到登录页，页面按角色显示可用功能
+	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := sessionFromRequest(r); !ok {
+			http.Redirect(w, r, "/admin/login", http.StatusFound)
+			return
+		}
+		b, err := webFS.ReadFile("web/admin.html")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(b)
+	})
+
+	// 统计数据，页面定时刷新
+	mux.HandleFunc("/api/admin/stats", requireRole(rolesAll, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		mutex.Lock()
+		st, err := collectAdminStatsLocked()
+		mutex.Unlock()
+		if err != nil {
+			log.Printf("读取答题结果错误: %v", err)
+		}
+		writeJSON(w, st)
+	}))
+
+	// 答题记录查询
+	setupRecordHandlers(mux)
+	setupNetworkHandlers(mux)
+	setupRateLimitHandlers(mux)
+	setupAuditHandlers(mux)
+
+	// 实时看板（SSE）
+	mux.HandleFunc("/api/admin/events", requireRole(rolesAll, serveDashboardEvents))
+
+	// 暂停/恢复接收答题
+	mux.HandleFunc("/api/admin/accepting", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Accepting bool `json:"accepting"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		setAccepting(req.Accepting)
+		auditRequest(r, AuditAccepting, map[string]string{"accepting": strconv.FormatBool(req.Accepting)})
+		writeJSON(w, map[string]bool{"accepting": req.Accepting})
+	}))
+
+	// 上传题库 Excel
+	mux.HandleFunc("/api/admin/questions", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		path, err := saveUpload(r, "file", "questions.xlsx")
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n, err := loadQuestionBank(path)
+		if err != nil {
+			writeError(w, "加载题库错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if onQuestionsChange != nil {
+			onQuestionsChange(n)
+		}
+		auditRequest(r, AuditQuestions, map[string]string{"questions": strconv.Itoa(n)})
+		writeJSON(w, map[string]interface{}{"message": fmt.Sprintf("已加载题库: %d 道题目", n)})
+	}))
+
+	// 上传兑换码 Excel（包含奖品等级）
+	mux.HandleFunc("/api/admin/codes", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		path, err := saveUpload(r, "file", "codes.xlsx")
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		levels, available, err := loadPrizeCodes(path, false)
+		if err != nil {
+			writeError(w, "加载兑换码错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		auditRequest(r, AuditCodes, map[string]string{"levels": strconv.Itoa(levels), "available": strconv.Itoa(available)})
+		writeJSON(w, map[string]interface{}{"message": fmt.Sprintf("已加载 %d 个奖品等级, %d 个可用兑换码", levels, available)})
+	}))
+
+	// 兑奖核销：兑奖员扫码后提交凭证内容，同一凭证只能核销一次
+	mux.HandleFunc("/api/admin/redeem", requireRole(rolesRedeem, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Voucher string `json:"voucher"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s, _ := sessionFromRequest(r)
+		p, red, err := redeemVoucher(req.Voucher, s.Username)
+		var invalid invalidVoucherError
+		switch {
+		case errors.Is(err, errAlreadyRedeemed):
+			writeError(w, fmt.Sprintf("%s（已于 %s 由 %s 核销）", err, red.Time.Format("2006-01-02 15:04:05"), red.By), http.StatusConflict)
+			return
+		case errors.As(err, &invalid):
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("兑奖核销错误: %v", err)
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("兑奖核销: %s %s (%s)", p.AttemptID, p.Level, s.Username)
+		audit(AuditRedeem, s.Username, clientIP(r), map[string]string{"attempt_id": p.AttemptID, "level": p.Level, "code": p.Code})
+		writeJSON(w, map[string]interface{}{"redeemed": true, "voucher": p, "redemption": red})
+	}))
+
+	// 题目分析：JSON 供页面显示，format=xlsx 时下载 Excel
+	mux.HandleFunc("/api/admin/analysis", requireRole(rolesAll, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		stats, err := itemAnalysis()
+		if err != nil {
+			log.Printf("题目分析错误: %v", err)
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("format") != "xlsx" {
+			writeJSON(w, stats)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", `attachment; filename="item-analysis.xlsx"`)
+		if err := WriteItemAnalysisExcel(w, stats); err != nil {
+			log.Printf("导出题目分析错误: %v", err)
+		}
+	}))
+
+	// 下载答题结果文件
+	mux.HandleFunc("/api/admin/results", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		// 提交答卷时会改写结果文件，持有 mutex 读出完整内容后再发送
+		mutex.Lock()
+		path := resultStoreLocked().Location()
+		fi, err := os.Stat(path)
+		var data []byte
+		if err == nil {
+			data, err = os.ReadFile(path)
+		}
+		mutex.Unlock()
+		if os.IsNotExist(err) {
+			writeError(w, "还没有答题记录", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("读取结果文件错误: %v", err)
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		auditRequest(r, AuditExport, map[string]string{"file": filepath.Base(path)})
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(path)))
+		http.ServeContent(w, r, filepath.Base(path), fi.ModTime(), bytes.NewReader(data))
+	}))
+}
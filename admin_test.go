@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResultsDownload(t *testing.T) {
+	mutex.Lock()
+	savedDir, savedFile := dataDir, resultsFile
+	dataDir = t.TempDir()
+	resultsFile = filepath.Join(dataDir, "records.xlsx")
+	path := resultsFile
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir, resultsFile = savedDir, savedFile
+		mutex.Unlock()
+		auditTailDir = ""
+	})
+
+	mux := http.NewServeMux()
+	setupAdminHandlers(mux)
+	get := func(c *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/admin/results", nil)
+		r.AddCookie(c)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	organizer := useTestSession(t, RoleOrganizer)
+
+	if w := get(organizer); w.Code != http.StatusNotFound {
+		t.Fatalf("/api/admin/results without records = %d, want 404", w.Code)
+	}
+	if err := os.WriteFile(path, []byte("xlsx data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w := get(organizer)
+	if w.Code != http.StatusOK || w.Body.String() != "xlsx data" {
+		t.Fatalf("/api/admin/results = %d %q", w.Code, w.Body.String())
+	}
+	if w := get(useTestSession(t, RoleClerk)); w.Code != http.StatusForbidden {
+		t.Fatalf("/api/admin/results as clerk = %d, want 403", w.Code)
+	}
+}
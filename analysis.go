@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// discriminationGroup 计算区分度时高分组、低分组各占的比例
+const discriminationGroup = 0.27
+
+// ItemStat 单道题目的作答分析
+type ItemStat struct {
+	ID             string   `json:"id"`
+	Prompt         string   `json:"question"`
+	Type           string   `json:"type"`
+	Options        []string `json:"options"`
+	Responses      int      `json:"responses"`       // 作答人数
+	Correct        int      `json:"correct"`         // 答对人数
+	CorrectRate    float64  `json:"correct_rate"`    // 正确率 0~1
+	OptionCounts   []int    `json:"option_counts"`   // 各选项被选次数，与 Options 对应
+	OtherCount     int      `json:"other_count"`     // 选了题库中已不存在的选项的次数
+	Discrimination float64  `json:"discrimination"`  // 区分度：高分组正确率 - 低分组正确率
+	AvgSeconds     float64  `json:"avg_seconds"`     // 平均用时（秒），没有用时数据时为 0
+	TimedResponses int      `json:"timed_responses"` // 有用时数据的作答数
+}
+
+// detailEntry 答题记录 detail 中单道题的内容
+type detailEntry struct {
+	Given   []string `json:"given"`
+	Correct bool     `json:"correct"`
+	Seconds float64  `json:"seconds"`
+}
+
+// parseDetail 解析答题记录中的 detail（刚提交的是 map，从文件读取的是 JSON）
+func parseDetail(rec ResultRecord) map[string]detailEntry {
+	if rec.Detail == nil {
+		return nil
+	}
+	b, err := json.Marshal(rec.Detail)
+	if err != nil {
+		return nil
+	}
+	out := map[string]detailEntry{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// AnalyzeItems 按当前题库统计每道题的正确率、选项分布、区分度和平均用时。
+// 区分度按总分排序后取前后各 27% 的答卷，比较两组在该题上的正确率。
+func AnalyzeItems(qs []Question, recs []ResultRecord) []ItemStat {
+	type sheet struct {
+		pct    float64
+		detail map[string]detailEntry
+	}
+	sheets := []sheet{}
+	for _, r := range recs {
+		d := parseDetail(r)
+		if len(d) == 0 || r.Total <= 0 {
+			continue
+		}
+		sheets = append(sheets, sheet{pct: float64(r.Score) / float64(r.Total), detail: d})
+	}
+	sort.SliceStable(sheets, func(i, j int) bool { return sheets[i].pct > sheets[j].pct })
+
+	n := int(math.Round(float64(len(sheets)) * discriminationGroup))
+	if n == 0 && len(sheets) >= 2 {
+		n = 1
+	}
+	upper, lower := sheets[:n], sheets[len(sheets)-n:]
+
+	// groupRate 某组在该题上的正确率，未作答的不计入
+	groupRate := func(group []sheet, id string) (float64, bool) {
+		answered, correct := 0, 0
+		for _, s := range group {
+			if e, ok := s.detail[id]; ok {
+				answered++
+				if e.Correct {
+					correct++
+				}
+			}
+		}
+		if answered == 0 {
+			return 0, false
+		}
+		return float64(correct) / float64(answered), true
+	}
+
+	stats := make([]ItemStat, 0, len(qs))
+	for _, q := range qs {
+		st := ItemStat{
+			ID:           q.ID,
+			Prompt:       q.Prompt,
+			Type:         q.Type,
+			Options:      q.Options,
+			OptionCounts: make([]int, len(q.Options)),
+		}
+		index := map[string]int{}
+		for i, o := range q.Options {
+			index[o] = i
+		}
+		seconds := 0.0
+		for _, s := range sheets {
+			e, ok := s.detail[q.ID]
+			if !ok {
+				continue
+			}
+			st.Responses++
+			if e.Correct {
+				st.Correct++
+			}
+			for _, g := range e.Given {
+				if i, ok := index[g]; ok {
+					st.OptionCounts[i]++
+				} else {
+					st.OtherCount++
+				}
+			}
+			if e.Seconds > 0 {
+				st.TimedResponses++
+				seconds += e.Seconds
+			}
+		}
+		if st.Responses > 0 {
+			st.CorrectRate = float64(st.Correct) / float64(st.Responses)
+		}
+		if st.TimedResponses > 0 {
+			st.AvgSeconds = math.Round(seconds/float64(st.TimedResponses)*10) / 10
+		}
+		pu, okU := groupRate(upper, q.ID)
+		pl, okL := groupRate(lower, q.ID)
+		if okU && okL {
+			st.Discrimination = math.Round((pu-pl)*100) / 100
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// itemAnalysis 使用当前题库和全部答题记录生成题目分析
+func itemAnalysis() ([]ItemStat, error) {
+	mutex.Lock()
+	qs := make([]Question, len(questions))
+	copy(qs, questions)
+	recs, err := recordsLocked()
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeItems(qs, recs), nil
+}
+
+// WriteItemAnalysisExcel 将题目分析写成 Excel，每道题一行，选项分布按选项分列
+func WriteItemAnalysisExcel(w io.Writer, stats []ItemStat) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "题目分析"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+	if err := writeItemAnalysisSheet(f, sheet, stats); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
+
+// writeItemAnalysisSheet 将题目分析写入工作簿中已存在的工作表
+func writeItemAnalysisSheet(f *excelize.File, sheet string, stats []ItemStat) error {
+	maxOptions := 0
+	for _, st := range stats {
+		if len(st.Options) > maxOptions {
+			maxOptions = len(st.Options)
+		}
+	}
+	header := []interface{}{"题号", "题目", "题型", "作答人数", "答对人数", "正确率", "区分度", "平均用时(秒)"}
+	for i := 0; i < maxOptions; i++ {
+		header = append(header, fmt.Sprintf("选项%c", 'A'+i))
+	}
+	header = append(header, "其它选项")
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+
+	for r, st := range stats {
+		row := []interface{}{st.ID, st.Prompt, st.Type, st.Responses, st.Correct,
+			fmt.Sprintf("%.1f%%", st.CorrectRate*100), st.Discrimination, st.AvgSeconds}
+		for i := 0; i < maxOptions; i++ {
+			if i >= len(st.Options) {
+				row = append(row, "")
+				continue
+			}
+			pct := 0.0
+			if st.Responses > 0 {
+				pct = float64(st.OptionCounts[i]) / float64(st.Responses) * 100
+			}
+			row = append(row, fmt.Sprintf("%s: %d (%.0f%%)", st.Options[i], st.OptionCounts[i], pct))
+		}
+		row = append(row, st.OtherCount)
+		cell, _ := excelize.CoordinatesToCellName(1, r+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	_ = f.SetColWidth(sheet, "B", "B", 50)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestAnalyzeItems(t *testing.T) {
+	qs := []Question{
+		{ID: "1", Type: "single", Prompt: "Q1", Options: []string{"A", "B", "C"}, Answer: []int{0}},
+		{ID: "2", Type: "multiple", Prompt: "Q2", Options: []string{"A", "B"}, Answer: []int{0, 1}},
+		{ID: "3", Type: "single", Prompt: "未作答", Options: []string{"A", "B"}, Answer: []int{1}},
+	}
+	sheet := func(score int, d map[string]detailEntry) ResultRecord {
+		return ResultRecord{Score: score, Total: 10, Detail: d}
+	}
+	recs := []ResultRecord{
+		sheet(10, map[string]detailEntry{"1": {Given: []string{"A"}, Correct: true, Seconds: 4}, "2": {Given: []string{"A", "B"}, Correct: true, Seconds: 8}}),
+		sheet(8, map[string]detailEntry{"1": {Given: []string{"A"}, Correct: true, Seconds: 6}, "2": {Given: []string{"A"}}}),
+		sheet(5, map[string]detailEntry{"1": {Given: []string{"B"}}, "2": {Given: []string{"A", "B"}, Correct: true}}),
+		sheet(2, map[string]detailEntry{"1": {Given: []string{"旧选项"}}, "2": {Given: []string{"B"}}}),
+		// 没有作答详情或总分为 0 的记录不参与分析
+		{Score: 10, Total: 10},
+		sheet(0, nil),
+	}
+	// 从结果文件读取的 detail 是 JSON
+	raw, _ := json.Marshal(map[string]detailEntry{"1": {Given: []string{"C"}}})
+	recs = append(recs, ResultRecord{Score: 0, Total: 10, Detail: json.RawMessage(raw)})
+
+	stats := AnalyzeItems(qs, recs)
+	if len(stats) != 3 {
+		t.Fatalf("AnalyzeItems() = %d items, want 3", len(stats))
+	}
+	q1 := stats[0]
+	if q1.Responses != 5 || q1.Correct != 2 || q1.CorrectRate != 0.4 {
+		t.Fatalf("item 1 responses = %+v", q1)
+	}
+	if want := []int{2, 1, 1}; q1.OptionCounts[0] != want[0] || q1.OptionCounts[1] != want[1] || q1.OptionCounts[2] != want[2] || q1.OtherCount != 1 {
+		t.Fatalf("item 1 option counts = %v other %d", q1.OptionCounts, q1.OtherCount)
+	}
+	// 5 份答卷取前后各 1 份（27% 四舍五入）：最高分答对，最低分答错
+	if q1.Discrimination != 1 {
+		t.Fatalf("item 1 discrimination = %v, want 1", q1.Discrimination)
+	}
+	if q1.TimedResponses != 2 || q1.AvgSeconds != 5 {
+		t.Fatalf("item 1 timing = %d responses, %v s", q1.TimedResponses, q1.AvgSeconds)
+	}
+	q2 := stats[1]
+	if q2.Responses != 4 || q2.Correct != 2 || q2.OptionCounts[0] != 3 || q2.OptionCounts[1] != 3 {
+		t.Fatalf("item 2 = %+v", q2)
+	}
+	if q3 := stats[2]; q3.Responses != 0 || q3.CorrectRate != 0 || q3.Discrimination != 0 {
+		t.Fatalf("unanswered item = %+v", q3)
+	}
+}
+
+func TestWriteItemAnalysisExcel(t *testing.T) {
+	stats := AnalyzeItems([]Question{{ID: "1", Type: "single", Prompt: "Q1", Options: []string{"A", "B"}, Answer: []int{0}}},
+		[]ResultRecord{{Score: 1, Total: 1, Detail: map[string]detailEntry{"1": {Given: []string{"A"}, Correct: true}}}})
+	var buf bytes.Buffer
+	if err := WriteItemAnalysisExcel(&buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(f.GetSheetList()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %v, want header and one item", rows)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errAttemptNotFound 答题编号不存在
+var errAttemptNotFound = errors.New("未找到答题记录")
+
+// attemptTokenTTL 答题令牌有效期，开始答题后超过该时间未提交需重新开始
+const attemptTokenTTL = 2 * time.Hour
+
+// attemptTokenHeader 下发题目时返回答题令牌的响应头，提交答卷时放在 token 字段中
+const attemptTokenHeader = "X-Attempt-Token"
+
+var (
+	// attempts 本次运行中提交的答题结果，按答题编号索引，调用方需持有 mutex
+	attempts = map[string]ResultRecord{}
+	// attemptTokens 已下发未使用的答题令牌及下发时间，调用方需持有 mutex
+	attemptTokens = map[string]time.Time{}
+)
+
+// newAttemptID 生成随机答题编号
+func newAttemptID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// 极少发生，退化为时间戳
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// issueAttemptTokenLocked 下发题目时生成一次性的答题令牌，提交答卷时须带上，
+// 用于拒绝没有经过答题页面伪造的提交，调用方需持有 mutex
+func issueAttemptTokenLocked(now time.Time) (string, error) {
+	for t, at := range attemptTokens {
+		if now.Sub(at) > attemptTokenTTL {
+			delete(attemptTokens, t)
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	attemptTokens[token] = now
+	return token, nil
+}
+
+// validAttemptTokenLocked 答题令牌是否有效（已下发、未使用且未过期），调用方需持有 mutex
+func validAttemptTokenLocked(token string, now time.Time) bool {
+	at, ok := attemptTokens[token]
+	return ok && now.Sub(at) <= attemptTokenTTL
+}
+
+// lookupAttemptLocked 按编号查找答题结果，内存中没有时（如程序重启后）从结果文件中查找，调用方需持有 mutex
+func lookupAttemptLocked(id string) (ResultRecord, bool, error) {
+	if id == "" {
+		// 旧版结果文件中的记录没有答题编号，不能用空编号匹配
+		return ResultRecord{}, false, nil
+	}
+	if rec, ok := attempts[id]; ok {
+		return rec, true, nil
+	}
+	recs, err := recordsLocked()
+	if err != nil {
+		return ResultRecord{}, false, err
+	}
+	rec, ok := findAttempt(recs, id)
+	if ok {
+		attempts[id] = rec
+	}
+	return rec, ok, nil
+}
+
+// attemptResponse 返回给奖励页面的结果数据，不包含姓名手机号等哈希信息
+func attemptResponse(rec ResultRecord) map[string]interface{} {
+	percentage := 0
+	if rec.Total > 0 {
+		percentage = int(float64(rec.Score) / float64(rec.Total) * 100)
+	}
+	resp := map[string]interface{}{
+		"attempt_id":  rec.AttemptID,
+		"score":       rec.Score,
+		"total":       rec.Total,
+		"percentage":  percentage,
+		"mask_name":   rec.MaskName,
+		"code":        rec.Award.Code,
+		"prize_level": rec.Award.Level,
+		"prize_kind":  rec.Award.Kind,
+		"prize":       rec.Award.Prize,
+		"time":        rec.Time.Format(time.RFC3339),
+	}
+	return resp
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计日志中的操作
+const (
+	AuditSubmit       = "submit"         // 提交答卷
+	AuditPrize        = "prize_issue"    // 发放奖品
+	AuditRedeem       = "redeem"         // 兑奖核销
+	AuditLogin        = "login"          // 管理员登录
+	AuditLoginFailed  = "login_failed"   // 登录失败
+	AuditLogout       = "logout"         // 注销
+	AuditAccepting    = "accepting"      // 暂停/恢复接收答题
+	AuditQuestions    = "load_questions" // 更新题库
+	AuditCodes        = "load_codes"     // 更新兑换码
+	AuditUserSave     = "user_save"      // 新建或修改账号
+	AuditUserDelete   = "user_delete"    // 删除账号
+	AuditNetwork      = "network"        // 修改访问地址
+	AuditUnban        = "unban"          // 解除封禁
+	AuditExport       = "export_results" // 下载答题结果
+	AuditImportConfig = "import_config"  // 导入活动配置
+)
+
+// auditActorDesktop 桌面管理界面的操作人
+const auditActorDesktop = "desktop"
+
+// AuditEntry 审计日志中的一行，Hash 为 sha256(Prev + 不含 Hash 的本行 JSON)，
+// 每行都包含上一行的 Hash，修改或删除其中任何一行都会使后面的校验失败
+type AuditEntry struct {
+	Seq    int64             `json:"seq"`
+	Time   time.Time         `json:"time"`
+	Action string            `json:"action"`
+	Actor  string            `json:"actor,omitempty"` // 管理员账号，参与者的操作为空
+	IP     string            `json:"ip,omitempty"`
+	Detail map[string]string `json:"detail,omitempty"`
+	Prev   string            `json:"prev"`
+	Hash   string            `json:"hash"`
+}
+
+// computeHash 计算本行的 Hash
+func (e AuditEntry) computeHash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(e.Prev), b...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var (
+	// auditLog 审计日志 logs/audit.jsonl，轮换后的旧文件全部保留
+	auditLog = &rotatingLog{name: "audit"}
+
+	// auditMu 保护审计链的末尾，写入顺序与链的顺序一致
+	auditMu sync.Mutex
+	// auditTail 最后一行的序号和 Hash，auditTailDir 为其所在的数据目录，数据目录变化后重新读取
+	auditTail    AuditEntry
+	auditTailDir string
+)
+
+// auditFiles 日志目录 dir 中全部审计日志文件，按时间从早到晚排列
+func auditFiles(dir string) []string {
+	files := auditLog.rotatedFiles(dir)
+	if _, err := os.Stat(auditLog.currentPath(dir)); err == nil {
+		files = append(files, auditLog.currentPath(dir))
+	}
+	return files
+}
+
+// lastAuditEntry 读取日志目录 dir 中最后一条审计记录，没有记录时返回零值
+func lastAuditEntry(dir string) (AuditEntry, error) {
+	files := auditFiles(dir)
+	for i := len(files) - 1; i >= 0; i-- {
+		var last AuditEntry
+		found := false
+		err := scanAuditFile(files[i], func(e AuditEntry) error {
+			last, found = e, true
+			return nil
+		})
+		if err != nil {
+			return AuditEntry{}, err
+		}
+		if found {
+			return last, nil
+		}
+	}
+	return AuditEntry{}, nil
+}
+
+// scanAuditFile 逐行读取审计日志
+func scanAuditFile(path string, fn func(AuditEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s 第 %d 行格式错误: %v", path, line, err)
+		}
+		if err := fn(e); err != nil {
+			return fmt.Errorf("%s 第 %d 行: %v", path, line, err)
+		}
+	}
+	return sc.Err()
+}
+
+// audit 在审计日志中追加一条记录，写入失败时只输出日志，不影响业务
+func audit(action, actor, ip string, detail map[string]string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	auditLocked(action, actor, ip, detail)
+}
+
+// auditLocked 同 audit，调用方需持有 mutex
+func auditLocked(action, actor, ip string, detail map[string]string) {
+	maxBytes, _ := logLimits()
+	e := AuditEntry{Time: eventNow(), Action: action, Actor: actor, IP: ip, Detail: detail}
+	if err := appendAudit(logsDir(), e, maxBytes); err != nil {
+		logOnce("audit", "写入审计日志错误: %v", err)
+	}
+}
+
+// auditRequest 记录网页管理后台的操作，操作人和来源 IP 取自请求
+func auditRequest(r *http.Request, action string, detail map[string]string) {
+	s, _ := sessionFromRequest(r)
+	audit(action, s.Username, clientIP(r), detail)
+}
+
+// appendAudit 填写序号和 Hash 后在日志目录 dir 中追加审计记录 e，文件超过 maxBytes 时轮换
+func appendAudit(dir string, e AuditEntry, maxBytes int64) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if auditTailDir != dir {
+		last, err := lastAuditEntry(dir)
+		if err != nil {
+			return err
+		}
+		auditTail, auditTailDir = last, dir
+	}
+	e.Seq, e.Prev = auditTail.Seq+1, auditTail.Hash
+	var err error
+	if e.Hash, err = e.computeHash(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := auditLog.writeLine(dir, line, maxBytes, 0); err != nil {
+		return err
+	}
+	auditTail = e
+	return nil
+}
+
+// verifyAuditLog 按顺序校验全部审计日志的 Hash 链，返回记录条数。
+// 轮换后的旧文件全部保留，第一条记录必须是序号 1，删除最早的记录或整个旧文件同样视为校验失败
+func verifyAuditLog() (int, error) {
+	mutex.Lock()
+	dir := logsDir()
+	mutex.Unlock()
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	n := 0
+	var prev AuditEntry
+	for _, path := range auditFiles(dir) {
+		err := scanAuditFile(path, func(e AuditEntry) error {
+			if n == 0 && (e.Seq != 1 || e.Prev != "") {
+				return fmt.Errorf("第一条记录的序号为 %d，之前的记录可能被删除", e.Seq)
+			}
+			if n > 0 && (e.Prev != prev.Hash || e.Seq != prev.Seq+1) {
+				return fmt.Errorf("序号 %d 与上一条记录（序号 %d）不连续，记录可能被删除或调换", e.Seq, prev.Seq)
+			}
+			h, err := e.computeHash()
+			if err != nil {
+				return err
+			}
+			if h != e.Hash {
+				return fmt.Errorf("序号 %d 的内容与 Hash 不符，记录可能被修改", e.Seq)
+			}
+			prev = e
+			n++
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// setupAuditHandlers 管理后台校验审计日志
+func setupAuditHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/api/admin/audit/verify", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		n, err := verifyAuditLog()
+		resp := map[string]interface{}{"entries": n, "valid": err == nil}
+		if err != nil {
+			resp["error"] = err.Error()
+		}
+		writeJSON(w, resp)
+	}))
+}
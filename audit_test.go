@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAuditLog 在临时数据目录中写入 n 条审计记录，maxBytes 较小时每条记录轮换为一个文件，返回日志目录
+func newAuditLog(t *testing.T, n int, maxBytes int64) string {
+	t.Helper()
+	mutex.Lock()
+	savedDir := dataDir
+	dataDir = t.TempDir()
+	dir := logsDir()
+	mutex.Unlock()
+	auditTailDir = ""
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir = savedDir
+		mutex.Unlock()
+		auditTailDir = ""
+	})
+	for i := 0; i < n; i++ {
+		e := AuditEntry{Time: time.Now(), Action: AuditSubmit, IP: "10.0.0.1", Detail: map[string]string{"score": "8/10"}}
+		if err := appendAudit(dir, e, maxBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auditLog.close()
+	return dir
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string)
+		ok     bool
+	}{
+		{"untouched", func(t *testing.T, dir string) {}, true},
+		{"edited entry", func(t *testing.T, dir string) {
+			rewriteAuditFile(t, auditFiles(dir)[1], func(s string) string { return strings.Replace(s, "8/10", "10/10", 1) })
+		}, false},
+		{"middle file removed", func(t *testing.T, dir string) {
+			_ = os.Remove(auditFiles(dir)[2])
+		}, false},
+		{"oldest file removed", func(t *testing.T, dir string) {
+			_ = os.Remove(auditFiles(dir)[0])
+		}, false},
+		{"files swapped", func(t *testing.T, dir string) {
+			files := auditFiles(dir)
+			a, _ := os.ReadFile(files[1])
+			b, _ := os.ReadFile(files[2])
+			_ = os.WriteFile(files[1], b, 0600)
+			_ = os.WriteFile(files[2], a, 0600)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newAuditLog(t, 5, 200)
+			if got := len(auditFiles(dir)); got != 5 {
+				t.Fatalf("audit files = %d, want 5", got)
+			}
+			tt.tamper(t, dir)
+			n, err := verifyAuditLog()
+			if (err == nil) != tt.ok {
+				t.Fatalf("verifyAuditLog() = %d, %v, want ok %v", n, err, tt.ok)
+			}
+			if tt.ok && n != 5 {
+				t.Fatalf("verifyAuditLog() = %d entries, want 5", n)
+			}
+		})
+	}
+}
+
+func TestAuditChainContinuesAcrossRestart(t *testing.T) {
+	dir := newAuditLog(t, 3, 1<<20)
+	// 重新启动后从文件中读取链尾
+	auditTailDir = ""
+	audit(AuditLogin, "admin", "10.0.0.2", nil)
+	auditLog.close()
+	n, err := verifyAuditLog()
+	if err != nil || n != 4 {
+		t.Fatalf("verifyAuditLog() = %d, %v, want 4 entries", n, err)
+	}
+	last, err := lastAuditEntry(dir)
+	if err != nil || last.Seq != 4 || last.Action != AuditLogin {
+		t.Fatalf("lastAuditEntry(dir) = %+v, %v", last, err)
+	}
+}
+
+func rewriteAuditFile(t *testing.T, path string, edit func(string) string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(edit(string(b))), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 管理后台角色
+const (
+	RoleOrganizer = "organizer" // 活动组织者，可使用全部管理功能
+	RoleClerk     = "clerk"     // 兑奖员，可核验和核销兑换凭证、查看统计
+	RoleViewer    = "viewer"    // 只能查看统计
+)
+
+// 各接口允许的角色
+var (
+	rolesAll       = []string{RoleOrganizer, RoleClerk, RoleViewer}
+	rolesRedeem    = []string{RoleOrganizer, RoleClerk}
+	rolesOrganizer = []string{RoleOrganizer}
+)
+
+const (
+	// sessionCookie 管理后台登录会话的 Cookie 名称
+	sessionCookie = "quiz_session"
+	// sessionTTL 登录会话有效期
+	sessionTTL = 12 * time.Hour
+	// passwordIter 密码哈希 PBKDF2 迭代次数
+	passwordIter = 120000
+	// minPasswordLen 密码（或 PIN）最短长度
+	minPasswordLen = 4
+
+	// 登录失败限制：窗口期内失败 maxLoginFailures 次后锁定 loginLockout
+	maxLoginFailures = 5
+	loginWindow      = 10 * time.Minute
+	loginLockout     = 10 * time.Minute
+)
+
+// AdminUser 管理后台账号，密码只保存加盐哈希
+type AdminUser struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Salt     string `json:"salt"`
+	Hash     string `json:"hash"`
+	Iter     int    `json:"iter"`
+}
+
+// adminSession 登录会话
+type adminSession struct {
+	Username string
+	Role     string
+	Expires  time.Time
+}
+
+// loginFailure 某个 IP 或账号的登录失败记录
+type loginFailure struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+var (
+	// adminUsers 管理后台账号，首次使用时从数据目录下的 admin_users.json 读取，调用方需持有 mutex
+	adminUsers map[string]AdminUser
+	// adminSessions 登录会话，按 Cookie 中的令牌索引，调用方需持有 mutex
+	adminSessions = map[string]adminSession{}
+	// loginFailures 登录失败计数，按 "ip:"（来源 IP）或 "user:"（账号@来源 IP）前缀区分，调用方需持有 mutex
+	loginFailures = map[string]*loginFailure{}
+)
+
+// errLoginLocked 登录失败次数过多
+var errLoginLocked = errors.New("登录失败次数过多，请稍后再试")
+
+// validRole 是否为有效角色
+func validRole(role string) bool {
+	for _, r := range rolesAll {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// hashPassword 计算密码哈希
+func hashPassword(password string, salt []byte, iter int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, password, salt, iter, 32)
+}
+
+// adminUsersPath 管理员账号文件位置
+func adminUsersPath() string {
+	return filepath.Join(dataDir, "admin_users.json")
+}
+
+// loadAdminUsersLocked 读取管理员账号，文件不存在时创建组织者账号 admin，
+// 初始密码为 admin.key 中的密码，调用方需持有 mutex
+func loadAdminUsersLocked() (map[string]AdminUser, error) {
+	if adminUsers != nil {
+		return adminUsers, nil
+	}
+	b, err := os.ReadFile(adminUsersPath())
+	if err == nil {
+		var list []AdminUser
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, fmt.Errorf("管理员账号文件格式错误: %v", err)
+		}
+		adminUsers = map[string]AdminUser{}
+		for _, u := range list {
+			adminUsers[u.Username] = u
+		}
+		return adminUsers, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	password, err := loadAdminPassword()
+	if err != nil {
+		return nil, err
+	}
+	u, err := newAdminUser(adminUser, RoleOrganizer, password)
+	if err != nil {
+		return nil, err
+	}
+	adminUsers = map[string]AdminUser{u.Username: u}
+	if err := saveAdminUsersLocked(); err != nil {
+		return nil, err
+	}
+	return adminUsers, nil
+}
+
+// saveAdminUsersLocked 保存管理员账号，调用方需持有 mutex
+func saveAdminUsersLocked() error {
+	list := make([]AdminUser, 0, len(adminUsers))
+	for _, u := range adminUsers {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := adminUsersPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, adminUsersPath())
+}
+
+// newAdminUser 校验并生成账号（计算密码哈希）
+func newAdminUser(username, role, password string) (AdminUser, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return AdminUser{}, errors.New("用户名不能为空")
+	}
+	if !validRole(role) {
+		return AdminUser{}, fmt.Errorf("角色 %q 无效", role)
+	}
+	if len(password) < minPasswordLen {
+		return AdminUser{}, fmt.Errorf("密码至少 %d 位", minPasswordLen)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return AdminUser{}, err
+	}
+	h, err := hashPassword(password, salt, passwordIter)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	return AdminUser{
+		Username: username,
+		Role:     role,
+		Salt:     hex.EncodeToString(salt),
+		Hash:     hex.EncodeToString(h),
+		Iter:     passwordIter,
+	}, nil
+}
+
+// setAdminUser 新建账号或修改已有账号的角色和密码
+func setAdminUser(username, role, password string) error {
+	u, err := newAdminUser(username, role, password)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	users, err := loadAdminUsersLocked()
+	if err != nil {
+		return err
+	}
+	if old, ok := users[u.Username]; ok && old.Role == RoleOrganizer && u.Role != RoleOrganizer && organizerCountLocked() == 1 {
+		return errors.New("至少需要保留一个组织者账号")
+	}
+	users[u.Username] = u
+	// 修改密码或角色后已有会话失效
+	dropSessionsLocked(u.Username)
+	return saveAdminUsersLocked()
+}
+
+// deleteAdminUser 删除账号，不能删除最后一个组织者
+func deleteAdminUser(username string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	users, err := loadAdminUsersLocked()
+	if err != nil {
+		return err
+	}
+	u, ok := users[username]
+	if !ok {
+		return fmt.Errorf("账号 %s 不存在", username)
+	}
+	if u.Role == RoleOrganizer && organizerCountLocked() == 1 {
+		return errors.New("至少需要保留一个组织者账号")
+	}
+	delete(users, username)
+	dropSessionsLocked(username)
+	return saveAdminUsersLocked()
+}
+
+// listAdminUsers 账号列表（不含密码哈希）
+func listAdminUsers() ([]map[string]string, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	users, err := loadAdminUsersLocked()
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]string{}
+	for _, u := range users {
+		out = append(out, map[string]string{"username": u.Username, "role": u.Role})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["username"] < out[j]["username"] })
+	return out, nil
+}
+
+// organizerCountLocked 组织者账号数量，调用方需持有 mutex
+func organizerCountLocked() int {
+	n := 0
+	for _, u := range adminUsers {
+		if u.Role == RoleOrganizer {
+			n++
+		}
+	}
+	return n
+}
+
+// dropSessionsLocked 注销某账号的全部会话，调用方需持有 mutex
+func dropSessionsLocked(username string) {
+	for token, s := range adminSessions {
+		if s.Username == username {
+			delete(adminSessions, token)
+		}
+	}
+}
+
+// loginBlockedLocked 该来源是否因失败次数过多被锁定，调用方需持有 mutex
+func loginBlockedLocked(key string, now time.Time) bool {
+	f, ok := loginFailures[key]
+	return ok && now.Before(f.lockedUntil)
+}
+
+// recordLoginFailureLocked 记录一次登录失败，调用方需持有 mutex
+func recordLoginFailureLocked(key string, now time.Time) {
+	f, ok := loginFailures[key]
+	if !ok || now.Sub(f.first) > loginWindow {
+		f = &loginFailure{first: now}
+		loginFailures[key] = f
+	}
+	f.count++
+	if f.count >= maxLoginFailures {
+		f.lockedUntil = now.Add(loginLockout)
+		f.count = 0
+		f.first = now
+	}
+}
+
+// clientIP 请求来源 IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// adminLogin 校验账号密码并创建会话，返回会话令牌
+func adminLogin(username, password, ip string) (string, adminSession, error) {
+	username = strings.TrimSpace(username)
+	// 只锁定失败来源的 IP，不锁定账号本身，避免局域网内有人故意输错密码把组织者锁在外面；
+	// 账号@IP 的计数不会因同一 IP 上其它账号登录成功而清零
+	ipKey, userKey := "ip:"+ip, "user:"+username+"@"+ip
+	now := time.Now()
+
+	mutex.Lock()
+	if loginBlockedLocked(ipKey, now) || loginBlockedLocked(userKey, now) {
+		mutex.Unlock()
+		return "", adminSession{}, errLoginLocked
+	}
+	users, err := loadAdminUsersLocked()
+	if err != nil {
+		mutex.Unlock()
+		return "", adminSession{}, err
+	}
+	u, ok := users[username]
+	mutex.Unlock()
+
+	// 账号不存在时也计算一次哈希，避免通过响应时间猜测账号
+	salt, _ := hex.DecodeString(u.Salt)
+	iter := u.Iter
+	if iter <= 0 {
+		iter = passwordIter
+	}
+	h, err := hashPassword(password, salt, iter)
+	if err != nil {
+		return "", adminSession{}, err
+	}
+	want, _ := hex.DecodeString(u.Hash)
+	valid := ok && subtle.ConstantTimeCompare(h, want) == 1
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !valid {
+		recordLoginFailureLocked(ipKey, now)
+		recordLoginFailureLocked(userKey, now)
+		return "", adminSession{}, errors.New("用户名或密码错误")
+	}
+	delete(loginFailures, ipKey)
+	delete(loginFailures, userKey)
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", adminSession{}, err
+	}
+	token := hex.EncodeToString(b)
+	s := adminSession{Username: u.Username, Role: u.Role, Expires: now.Add(sessionTTL)}
+	adminSessions[token] = s
+	return token, s, nil
+}
+
+// sessionFromRequest 读取请求中的登录会话
+func sessionFromRequest(r *http.Request) (adminSession, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return adminSession{}, false
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	s, ok := adminSessions[c.Value]
+	if !ok {
+		return adminSession{}, false
+	}
+	if time.Now().After(s.Expires) {
+		delete(adminSessions, c.Value)
+		return adminSession{}, false
+	}
+	return s, true
+}
+
+// hasRole 角色是否在允许列表中
+func hasRole(role string, allowed []string) bool {
+	for _, r := range allowed {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requireRole 要求已登录且角色在允许列表中，未登录返回 401，无权限返回 403
+func requireRole(allowed []string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := sessionFromRequest(r)
+		if !ok {
+			writeError(w, "请先登录", http.StatusUnauthorized)
+			return
+		}
+		if !hasRole(s.Role, allowed) {
+			writeError(w, "没有权限", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// setupAuthHandlers 登录、注销和账号管理接口
+func setupAuthHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/login", func(w http.ResponseWriter, r *http.Request) {
+		b, err := webFS.ReadFile("web/login.html")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(b)
+	})
+
+	mux.HandleFunc("/api/admin/login", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		token, s, err := adminLogin(req.Username, req.Password, clientIP(r))
+		if err != nil {
+			audit(AuditLoginFailed, req.Username, clientIP(r), map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, errLoginLocked) {
+			writeError(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			writeError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/",
+			MaxAge:   int(sessionTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		audit(AuditLogin, s.Username, clientIP(r), map[string]string{"role": s.Role})
+		writeJSON(w, map[string]string{"username": s.Username, "role": s.Role})
+	})
+
+	mux.HandleFunc("/api/admin/logout", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		if s, ok := sessionFromRequest(r); ok {
+			audit(AuditLogout, s.Username, clientIP(r), nil)
+		}
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			mutex.Lock()
+			delete(adminSessions, c.Value)
+			mutex.Unlock()
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+		writeJSON(w, map[string]bool{"ok": true})
+	})
+
+	mux.HandleFunc("/api/admin/me", requireRole(rolesAll, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		s, _ := sessionFromRequest(r)
+		writeJSON(w, map[string]string{"username": s.Username, "role": s.Role})
+	}))
+
+	// 账号管理：GET 列表，POST 新建或修改，DELETE 删除（?username=）
+	mux.HandleFunc("/api/admin/users", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			list, err := listAdminUsers()
+			if err != nil {
+				writeError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, list)
+		case http.MethodPost:
+			var req struct {
+				Username string `json:"username"`
+				Role     string `json:"role"`
+				Password string `json:"password"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := setAdminUser(req.Username, req.Role, req.Password); err != nil {
+				writeError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			auditRequest(r, AuditUserSave, map[string]string{"username": req.Username, "role": req.Role})
+			writeJSON(w, map[string]bool{"ok": true})
+		case http.MethodDelete:
+			if err := deleteAdminUser(r.URL.Query().Get("username")); err != nil {
+				writeError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			auditRequest(r, AuditUserDelete, map[string]string{"username": r.URL.Query().Get("username")})
+			writeJSON(w, map[string]bool{"ok": true})
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+	}))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useAdminUsers 在临时数据目录中使用给定的账号（账号名 -> 角色，密码均为 pass1234），测试结束后恢复
+func useAdminUsers(t *testing.T, roles map[string]string) {
+	t.Helper()
+	users := map[string]AdminUser{}
+	for name, role := range roles {
+		u, err := newAdminUser(name, role, "pass1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[name] = u
+	}
+	mutex.Lock()
+	savedDir, savedUsers, savedSessions, savedFailures := dataDir, adminUsers, adminSessions, loginFailures
+	dataDir, adminUsers, adminSessions, loginFailures = t.TempDir(), users, map[string]adminSession{}, map[string]*loginFailure{}
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir, adminUsers, adminSessions, loginFailures = savedDir, savedUsers, savedSessions, savedFailures
+		mutex.Unlock()
+	})
+}
+
+// useTestSession 登录一个指定角色的测试账号，返回会话 Cookie，测试结束后注销
+func useTestSession(t *testing.T, role string) *http.Cookie {
+	t.Helper()
+	token := "test-" + role
+	mutex.Lock()
+	adminSessions[token] = adminSession{Username: "test-" + role, Role: role, Expires: time.Now().Add(time.Hour)}
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		delete(adminSessions, token)
+		mutex.Unlock()
+	})
+	return &http.Cookie{Name: sessionCookie, Value: token}
+}
+
+func TestAdminLoginLockout(t *testing.T) {
+	useAdminUsers(t, map[string]string{"boss": RoleOrganizer, "c1": RoleClerk})
+
+	token, s, err := adminLogin(" c1 ", "pass1234", "10.0.0.1")
+	if err != nil || token == "" || s.Username != "c1" || s.Role != RoleClerk {
+		t.Fatalf("adminLogin() = %q, %+v, %v", token, s, err)
+	}
+	for i := 0; i < maxLoginFailures; i++ {
+		if _, _, err := adminLogin("boss", "wrong", "10.0.0.2"); err == nil || errors.Is(err, errLoginLocked) {
+			t.Fatalf("failed login %d error = %v", i+1, err)
+		}
+	}
+	// 失败次数过多后该 IP 即使密码正确也被锁定，其它 IP 不受影响
+	if _, _, err := adminLogin("boss", "pass1234", "10.0.0.2"); !errors.Is(err, errLoginLocked) {
+		t.Fatalf("login from locked IP error = %v, want locked", err)
+	}
+	if _, _, err := adminLogin("boss", "pass1234", "10.0.0.3"); err != nil {
+		t.Fatalf("login from another IP error = %v", err)
+	}
+	if _, _, err := adminLogin("nobody", "pass1234", "10.0.0.3"); err == nil {
+		t.Fatal("login as unknown user succeeded")
+	}
+}
+
+func TestAdminUserRules(t *testing.T) {
+	useAdminUsers(t, map[string]string{"boss": RoleOrganizer})
+
+	if err := setAdminUser("c1", "cashier", "pass1234"); err == nil {
+		t.Fatal("setAdminUser() accepted an invalid role")
+	}
+	if err := setAdminUser("c1", RoleClerk, "123"); err == nil {
+		t.Fatal("setAdminUser() accepted a short password")
+	}
+	if err := setAdminUser("c1", RoleClerk, "pass1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setAdminUser("boss", RoleViewer, "pass1234"); err == nil {
+		t.Fatal("setAdminUser() demoted the last organizer")
+	}
+	if err := deleteAdminUser("boss"); err == nil {
+		t.Fatal("deleteAdminUser() removed the last organizer")
+	}
+
+	// 修改密码后已有会话失效
+	token, _, err := adminLogin("c1", "pass1234", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setAdminUser("c1", RoleClerk, "newpass1"); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+	if _, ok := sessionFromRequest(r); ok {
+		t.Fatal("session still valid after the password changed")
+	}
+	if err := deleteAdminUser("c1"); err != nil {
+		t.Fatal(err)
+	}
+	users, err := listAdminUsers()
+	if err != nil || len(users) != 1 || users[0]["username"] != "boss" {
+		t.Fatalf("listAdminUsers() = %v, %v", users, err)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	useAdminUsers(t, nil)
+	h := requireRole(rolesRedeem, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"not logged in", nil, http.StatusUnauthorized},
+		{"unknown session", &http.Cookie{Name: sessionCookie, Value: "nope"}, http.StatusUnauthorized},
+		{"viewer", useTestSession(t, RoleViewer), http.StatusForbidden},
+		{"clerk", useTestSession(t, RoleClerk), http.StatusNoContent},
+		{"organizer", useTestSession(t, RoleOrganizer), http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/admin/redeem", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
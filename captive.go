@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// captiveDNSAddr 热点模式下 DNS 服务的监听地址
+	captiveDNSAddr = ":53"
+	// captiveHTTPAddr 热点模式下用于接收系统联网检测的 HTTP 地址
+	captiveHTTPAddr = ":80"
+	// captiveDNSTTL DNS 回复的有效时间（秒），较短以便离开热点后尽快恢复正常解析
+	captiveDNSTTL = 10
+)
+
+// captiveProbePaths 各系统联网检测使用的路径，热点模式下重定向到身份填写页，手机加入热点后会自动弹出答题页面
+var captiveProbePaths = map[string]bool{
+	"/generate_204":                  true, // Android、Chrome
+	"/gen_204":                       true,
+	"/hotspot-detect.html":           true, // iOS、macOS
+	"/library/test/success.html":     true,
+	"/ncsi.txt":                      true, // Windows
+	"/connecttest.txt":               true,
+	"/redirect":                      true,
+	"/success.txt":                   true, // Firefox
+	"/canonical.html":                true,
+	"/check_network_status.txt":      true, // 部分国产手机
+	"/generate204":                   true,
+	"/mobile/status.php":             true,
+	"/kindle-wifi/wifistub.html":     true,
+	"/kindle-wifi/wifiredirect.html": true,
+}
+
+var (
+	// captivePortal 是否启用热点模式（下次启动服务时生效），调用方需持有 mutex
+	captivePortal bool
+	// captiveResponder 正在运行的热点模式 DNS 和 HTTP 服务，调用方需持有 serverMu
+	captiveResponder *captiveServer
+)
+
+// setCaptivePortal 启用或关闭热点模式（下次启动服务时生效），并保存到活动配置
+func setCaptivePortal(on bool) error {
+	mutex.Lock()
+	captivePortal = on
+	mutex.Unlock()
+	return saveCurrentEventConfig()
+}
+
+// captiveRedirect 重定向到身份填写页
+func captiveRedirect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, publicURL()+"/identity.html", http.StatusFound)
+}
+
+// isOwnHost 请求的主机名是否为本机：IP 地址、管理员设置的地址、mDNS 名称或 localhost
+func isOwnHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || host == "localhost" || net.ParseIP(host) != nil {
+		return true
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	return host == strings.ToLower(advertiseHost) || host == mdnsHostLocked()
+}
+
+// captiveHandler 热点模式下把系统联网检测和访问其它网站的请求重定向到身份填写页，其余交给 next
+func captiveHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if captiveProbePaths[r.URL.Path] || !isOwnHost(r.Host) {
+			captiveRedirect(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// captiveServer 热点模式：DNS 对所有查询都回答本机地址，并在 80 端口把所有请求重定向到答题页面
+type captiveServer struct {
+	conn *net.UDPConn
+	http *http.Server // 答题服务本身监听 80 端口时为 nil
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startCaptivePortal 启动热点模式，port 为答题服务实际监听的端口。
+// 监听 53 和 80 端口通常需要管理员权限，失败时返回错误，答题服务仍可正常使用
+func startCaptivePortal(port int) (*captiveServer, error) {
+	addr, err := net.ResolveUDPAddr("udp4", captiveDNSAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("热点模式无法监听 DNS 端口: %v", err)
+	}
+	s := &captiveServer{conn: conn, done: make(chan struct{})}
+	if _, p, _ := net.SplitHostPort(captiveHTTPAddr); p != fmt.Sprint(port) {
+		ln, err := net.Listen("tcp", captiveHTTPAddr)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("热点模式无法监听 HTTP 端口: %v", err)
+		}
+		s.http = &http.Server{Handler: http.HandlerFunc(captiveRedirect), ReadHeaderTimeout: 10 * time.Second}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.http.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Printf("热点模式 HTTP 服务错误: %v", err)
+			}
+		}()
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// close 停止热点模式的 DNS 和 HTTP 服务
+func (s *captiveServer) close() {
+	close(s.done)
+	_ = s.conn.Close()
+	if s.http != nil {
+		_ = s.http.Close()
+	}
+	s.wg.Wait()
+}
+
+// serve 读取 DNS 查询并回复
+func (s *captiveServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				log.Printf("热点模式 DNS 读取错误: %v", err)
+			}
+			return
+		}
+		msg, err := captiveDNSResponse(buf[:n], localIPFor(src.IP))
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteToUDP(msg, src)
+	}
+}
+
+// captiveDNSResponse 生成对 query 的回复：A 查询回答 ip，其它类型（如 AAAA）回答无记录，
+// 手机因此只会使用 IPv4 访问本机
+func captiveDNSResponse(query []byte, ip net.IP) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil || h.Response {
+		return nil, fmt.Errorf("not a query")
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 h.ID,
+		Response:           true,
+		Authoritative:      true,
+		RecursionDesired:   h.RecursionDesired,
+		RecursionAvailable: true,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if ip4 := ip.To4(); ip4 != nil && q.Class == dnsmessage.ClassINET && (q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL) {
+		var a [4]byte
+		copy(a[:], ip4)
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: captiveDNSTTL}
+		if err := b.AResource(rh, dnsmessage.AResource{A: a}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestCaptiveDNSResponse(t *testing.T) {
+	query := func(typ dnsmessage.Type, response bool) []byte {
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 42, Response: response, RecursionDesired: true})
+		_ = b.StartQuestions()
+		_ = b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName("connectivitycheck.gstatic.com."), Type: typ, Class: dnsmessage.ClassINET})
+		msg, err := b.Finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	ip := net.IPv4(192, 168, 43, 1)
+	tests := []struct {
+		name    string
+		query   []byte
+		ip      net.IP
+		answers int
+		err     bool
+	}{
+		{"A", query(dnsmessage.TypeA, false), ip, 1, false},
+		{"ANY", query(dnsmessage.TypeALL, false), ip, 1, false},
+		{"AAAA", query(dnsmessage.TypeAAAA, false), ip, 0, false},
+		{"no local address", query(dnsmessage.TypeA, false), nil, 0, false},
+		{"response", query(dnsmessage.TypeA, true), ip, 0, true},
+		{"garbage", []byte{1, 2, 3}, ip, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := captiveDNSResponse(tt.query, tt.ip)
+			if (err != nil) != tt.err {
+				t.Fatalf("captiveDNSResponse() error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			var m dnsmessage.Message
+			if err := m.Unpack(b); err != nil {
+				t.Fatal(err)
+			}
+			if m.ID != 42 || !m.Response || !m.RecursionDesired || len(m.Questions) != 1 || len(m.Answers) != tt.answers {
+				t.Fatalf("reply = %+v, %d questions, %d answers", m.Header, len(m.Questions), len(m.Answers))
+			}
+			if tt.answers == 0 {
+				return
+			}
+			a, ok := m.Answers[0].Body.(*dnsmessage.AResource)
+			if !ok || a.A != [4]byte{192, 168, 43, 1} || m.Answers[0].Header.TTL != captiveDNSTTL || m.Answers[0].Header.Name != m.Questions[0].Name {
+				t.Fatalf("answer = %+v", m.Answers[0])
+			}
+		})
+	}
+}
+
+func TestCaptiveHandler(t *testing.T) {
+	mutex.Lock()
+	savedAdv, savedMDNS := advertiseHost, mdnsName
+	advertiseHost, mdnsName = "quiz.example.com", "quiz-test"
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		advertiseHost, mdnsName = savedAdv, savedMDNS
+		mutex.Unlock()
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		host, path string
+		redirect   bool
+	}{
+		{"192.168.43.1:8080", "/identity.html", false},
+		{"localhost:8080", "/", false},
+		{"Quiz.Example.com", "/api/status", false},
+		{"quiz-test.local.", "/", false},
+		{"192.168.43.1:8080", "/generate_204", true},
+		{"captive.apple.com", "/hotspot-detect.html", true},
+		{"www.msftconnecttest.com", "/connecttest.txt", true},
+		{"www.example.org", "/", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+		w := httptest.NewRecorder()
+		captiveHandler(next).ServeHTTP(w, r)
+		if redirected := w.Code == http.StatusFound; redirected != tt.redirect {
+			t.Errorf("%s%s = %d, want redirect %v", tt.host, tt.path, w.Code, tt.redirect)
+		}
+		if tt.redirect && !strings.HasSuffix(w.Header().Get("Location"), "/identity.html") {
+			t.Errorf("%s%s redirected to %q", tt.host, tt.path, w.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// 凭证图片尺寸（像素）
+const (
+	certWidth  = 900
+	certHeight = 560
+)
+
+var (
+	// certFontPath 生成凭证图片使用的中文字体，为空时自动在常见系统字体中查找
+	certFontPath = ""
+
+	certFontOnce sync.Once
+	certFontData *opentype.Font
+	certFontCJK  bool
+)
+
+// cjkFontCandidates 常见系统中文字体位置（Windows / macOS / Linux / Android）
+var cjkFontCandidates = []string{
+	`C:\Windows\Fonts\msyh.ttc`,
+	`C:\Windows\Fonts\simhei.ttf`,
+	`C:\Windows\Fonts\simsun.ttc`,
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Medium.ttc",
+	"/Library/Fonts/Arial Unicode.ttf",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/wqy-microhei/wqy-microhei.ttc",
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+	"/system/fonts/NotoSansCJK-Regular.ttc",
+	"/system/fonts/DroidSansFallback.ttf",
+}
+
+// parseFontFile 解析 ttf/otf 或 ttc 字体文件（ttc 取第一个字体）
+func parseFontFile(path string) (*opentype.Font, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := opentype.Parse(b); err == nil {
+		return f, nil
+	}
+	c, err := opentype.ParseCollection(b)
+	if err != nil {
+		return nil, err
+	}
+	return c.Font(0)
+}
+
+// loadCertFont 加载中文字体，找不到时退回内置的 Go 字体（只能显示英文，标签改用英文）
+func loadCertFont() (*opentype.Font, bool) {
+	certFontOnce.Do(func() {
+		paths := cjkFontCandidates
+		if certFontPath != "" {
+			paths = append([]string{certFontPath}, paths...)
+		}
+		for _, p := range paths {
+			if f, err := parseFontFile(p); err == nil {
+				certFontData, certFontCJK = f, true
+				return
+			}
+		}
+		log.Println("未找到中文字体，凭证图片将使用英文标签并省略姓名、奖品中的中文，可安装 Noto Sans CJK 或文泉驿微米黑字体")
+		certFontData, _ = opentype.Parse(goregular.TTF)
+	})
+	return certFontData, certFontCJK
+}
+
+// isASCII 内置的 Go 字体能否显示
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// certValue 没有中文字体时，内置字体无法显示的内容留空（如中文姓名），完整信息仍在核验二维码中
+func certValue(cjk bool, s string) string {
+	if cjk || isASCII(s) {
+		return s
+	}
+	return ""
+}
+
+// certCanvas 凭证画布，封装文字绘制
+type certCanvas struct {
+	img   *image.RGBA
+	font  *opentype.Font
+	faces map[float64]font.Face
+}
+
+func (c *certCanvas) face(size float64) font.Face {
+	if f, ok := c.faces[size]; ok {
+		return f
+	}
+	f, err := opentype.NewFace(c.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil
+	}
+	c.faces[size] = f
+	return f
+}
+
+// text 在 (x, y) 处绘制文字，y 为基线位置
+func (c *certCanvas) text(x, y int, size float64, col color.Color, s string) {
+	face := c.face(size)
+	if face == nil {
+		return
+	}
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// centerText 水平居中绘制文字
+func (c *certCanvas) centerText(y int, size float64, col color.Color, s string) {
+	face := c.face(size)
+	if face == nil {
+		return
+	}
+	w := font.MeasureString(face, s).Ceil()
+	c.text((certWidth-w)/2, y, size, col, s)
+}
+
+// certLabels 凭证上的文字标签，没有中文字体时使用英文
+func certLabels(cjk bool) map[string]string {
+	if cjk {
+		return map[string]string{
+			"title": "反诈知识答题", "voucher": "兑换凭证", "certificate": "参与证书",
+			"name": "姓名", "score": "得分", "level": "奖品等级", "code": "兑换码", "prize": "奖品",
+			"time": "时间", "id": "编号", "scan": "扫码核验",
+		}
+	}
+	return map[string]string{
+		"title": "Anti-Fraud Quiz", "voucher": "Reward Voucher", "certificate": "Certificate of Participation",
+		"name": "Name", "score": "Score", "level": "Prize level", "code": "Code", "prize": "Prize",
+		"time": "Time", "id": "ID", "scan": "Scan to verify",
+	}
+}
+
+// RenderCertificate 生成包含脱敏姓名、得分、奖品及核验二维码的凭证图片，
+// 没有中文字体时省略无法显示的中文内容
+func RenderCertificate(rec ResultRecord, voucher string) (image.Image, error) {
+	fnt, cjk := loadCertFont()
+	if fnt == nil {
+		return nil, fmt.Errorf("无法加载字体")
+	}
+	lb := certLabels(cjk)
+	if cjk {
+		lb["title"] = currentBranding().Title
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, certWidth, certHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0xf4, 0xf8, 0xff, 0xff}), image.Point{}, draw.Src)
+	// 顶部色条与边框
+	blue := color.RGBA{0x00, 0x66, 0xcc, 0xff}
+	draw.Draw(img, image.Rect(0, 0, certWidth, 96), image.NewUniform(blue), image.Point{}, draw.Src)
+	for _, r := range []image.Rectangle{
+		image.Rect(0, certHeight-6, certWidth, certHeight),
+		image.Rect(0, 0, 6, certHeight),
+		image.Rect(certWidth-6, 0, certWidth, certHeight),
+	} {
+		draw.Draw(img, r, image.NewUniform(blue), image.Point{}, draw.Src)
+	}
+
+	c := &certCanvas{img: img, font: fnt, faces: map[float64]font.Face{}}
+	kind := lb["voucher"]
+	if rec.Award.Level == "" || rec.Award.Kind == PrizeKindConsolation {
+		kind = lb["certificate"]
+	}
+	c.centerText(62, 36, color.White, lb["title"]+" · "+kind)
+
+	dark := color.RGBA{0x10, 0x24, 0x40, 0xff}
+	grey := color.RGBA{0x5a, 0x6b, 0x80, 0xff}
+	var lines [][2]string
+	add := func(label, value string) {
+		if value = certValue(cjk, value); value != "" {
+			lines = append(lines, [2]string{label, value})
+		}
+	}
+	add(lb["name"], rec.MaskName)
+	add(lb["score"], fmt.Sprintf("%d / %d", rec.Score, rec.Total))
+	add(lb["level"], rec.Award.Level)
+	add(lb["code"], rec.Award.Code)
+	add(lb["prize"], rec.Award.Prize)
+	add(lb["time"], rec.Time.Format("2006-01-02 15:04"))
+
+	y := 160
+	for _, l := range lines {
+		c.text(60, y, 22, grey, l[0])
+		c.text(210, y, 28, dark, l[1])
+		y += 56
+	}
+	c.text(60, certHeight-30, 16, grey, lb["id"]+": "+rec.AttemptID)
+
+	// 核验二维码，未签发凭证时只显示编号
+	if voucher == "" {
+		return img, nil
+	}
+	qr, err := qrcode.New(voucher, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	qrImg := qr.Image(280)
+	qrRect := image.Rect(certWidth-340, 130, certWidth-60, 410)
+	draw.Draw(img, qrRect, qrImg, image.Point{}, draw.Src)
+	c.text(certWidth-340+(280-font.MeasureString(c.face(18), lb["scan"]).Ceil())/2, 440, 18, grey, lb["scan"])
+
+	return img, nil
+}
+
+// certificatePNG 生成 PNG 格式凭证
+func certificatePNG(rec ResultRecord, voucher string) ([]byte, error) {
+	img, err := RenderCertificate(rec, voucher)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// certificatePDF 生成单页 PDF 格式凭证（将凭证图片嵌入页面）
+func certificatePDF(rec ResultRecord, voucher string) ([]byte, error) {
+	img, err := RenderCertificate(rec, voucher)
+	if err != nil {
+		return nil, err
+	}
+	return encodeImagePDF(img)
+}
+
+// encodeImagePDF 将图片写成最简单的单页 PDF，页面大小按 96 DPI 换算
+func encodeImagePDF(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	raw := make([]byte, 0, w*h*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			raw = append(raw, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	pw, ph := float64(w)*0.75, float64(h)*0.75
+	content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", pw, ph)
+
+	var out bytes.Buffer
+	offsets := []int{}
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 4 0 R >> >> /Contents 5 0 R >>", pw, ph), nil)
+	obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>", w, h, z.Len()), z.Bytes())
+	obj(fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+// certificateForAttempt 按答题编号生成凭证文件，format 为 png 或 pdf，返回文件内容和 Content-Type
+func certificateForAttempt(id, format string) ([]byte, string, error) {
+	mutex.Lock()
+	rec, ok, err := lookupAttemptLocked(id)
+	signVoucher := voucherEnabled
+	mutex.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return nil, "", errAttemptNotFound
+	}
+	voucher := ""
+	if signVoucher {
+		if voucher, err = signRecord(rec); err != nil {
+			log.Printf("签发兑换凭证错误: %v", err)
+		}
+	}
+	if strings.EqualFold(format, "pdf") {
+		b, err := certificatePDF(rec, voucher)
+		return b, "application/pdf", err
+	}
+	b, err := certificatePNG(rec, voucher)
+	return b, "image/png", err
+}
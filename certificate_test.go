@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// useCertFont 使用内置的 Go 字体（没有中文字体）生成凭证，测试结束后恢复
+func useCertFont(t *testing.T) {
+	t.Helper()
+	loadCertFont()
+	savedFont, savedCJK := certFontData, certFontCJK
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFontData, certFontCJK = f, false
+	t.Cleanup(func() { certFontData, certFontCJK = savedFont, savedCJK })
+}
+
+func TestRenderCertificateWithoutCJKFont(t *testing.T) {
+	useCertFont(t)
+	tm := time.Date(2026, 10, 18, 15, 4, 0, 0, time.UTC)
+	ascii := ResultRecord{AttemptID: "a1", MaskName: "L*i", Score: 9, Total: 10, Time: tm, Award: Award{Level: "First", Kind: PrizeKindCode, Code: "AAAA-1111"}}
+	chinese := ResultRecord{AttemptID: "a2", MaskName: "张*", Score: 9, Total: 10, Time: tm, Award: Award{Level: "一等奖", Kind: PrizeKindItem, Prize: "雨伞"}}
+	tests := []struct {
+		name    string
+		rec     ResultRecord
+		voucher string
+	}{
+		{"ascii name with voucher", ascii, "QZV1.test.sig"},
+		{"chinese name with voucher", chinese, "QZV1.test.sig"},
+		{"chinese name without voucher", chinese, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := RenderCertificate(tt.rec, tt.voucher)
+			if err != nil {
+				t.Fatalf("RenderCertificate() error = %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != certWidth || b.Dy() != certHeight {
+				t.Fatalf("certificate size = %v", b)
+			}
+			// 有凭证时右侧绘制核验二维码
+			black := 0
+			for y := 130; y < 410; y++ {
+				for x := certWidth - 340; x < certWidth-60; x++ {
+					if img.At(x, y) == (color.RGBA{0, 0, 0, 0xff}) {
+						black++
+					}
+				}
+			}
+			if drawn := black > 0; drawn != (tt.voucher != "") {
+				t.Fatalf("QR code drawn = %v, want %v", drawn, tt.voucher != "")
+			}
+			pdf, err := certificatePDF(tt.rec, tt.voucher)
+			if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+				t.Fatalf("certificatePDF() = %d bytes, %v", len(pdf), err)
+			}
+		})
+	}
+}
+
+func TestCertValue(t *testing.T) {
+	if got := certValue(false, "张*"); got != "" {
+		t.Fatalf("certValue(false, chinese) = %q, want empty", got)
+	}
+	if got := certValue(false, "AAAA-1111"); got != "AAAA-1111" {
+		t.Fatalf("certValue(false, ascii) = %q", got)
+	}
+	if got := certValue(true, "张*"); got != "张*" {
+		t.Fatalf("certValue(true, chinese) = %q", got)
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 奖品等级发完时的处理方式
+const (
+	FallbackDowngrade   = "downgrade"   // 尝试更低的等级（默认）
+	FallbackConsolation = "consolation" // 直接发放参与奖
+	FallbackNone        = "none"        // 不再发放奖品
+)
+
+// 参与限制
+const (
+	ParticipationDaily     = "daily"     // 每人每天限答 MaxPerDay 次（默认 1 次）
+	ParticipationUnlimited = "unlimited" // 不限制答题次数
+)
+
+// EventConfig 活动配置，按扩展名读取 TOML (.toml) 或 JSON，默认是数据目录下的 event.json。
+// 运行中的修改只保存到数据目录下的 event.json，不会改写通过 -config 指定的配置文件
+type EventConfig struct {
+	DataDir         string              `json:"data_dir,omitempty" toml:"data_dir,omitempty"`               // 数据目录（凭证密钥、默认结果文件等）
+	QuestionBank    string              `json:"question_bank,omitempty" toml:"question_bank,omitempty"`     // 题库 Excel
+	CodesFile       string              `json:"codes_file,omitempty" toml:"codes_file,omitempty"`           // 兑换码 Excel
+	ListenAddr      string              `json:"listen_addr,omitempty" toml:"listen_addr,omitempty"`         // 监听地址，如 :8080
+	ListenFallback  bool                `json:"listen_fallback,omitempty" toml:"listen_fallback,omitempty"` // 端口被占用时自动换用后面的空闲端口
+	TLS             bool                `json:"tls,omitempty" toml:"tls,omitempty"`                         // 以 HTTPS 提供服务，证书由本机生成的根证书签发
+	MDNSName        string              `json:"mdns_name,omitempty" toml:"mdns_name,omitempty"`             // 通过 mDNS 广播的主机名（如 quiz-branch01，访问 quiz-branch01.local），为空时不广播
+	AdvertiseHost   string              `json:"advertise_host,omitempty" toml:"advertise_host,omitempty"`   // 二维码中使用的 IP 或主机名，为空时自动检测
+	CaptivePortal   bool                `json:"captive_portal,omitempty" toml:"captive_portal,omitempty"`   // 热点模式：DNS 回答本机地址，手机加入热点后自动弹出答题页面
+	MetricsToken    string              `json:"metrics_token,omitempty" toml:"metrics_token,omitempty"`     // 访问 /metrics 需要的令牌（Authorization: Bearer），为空时只允许本机访问
+	TimeZone        string              `json:"time_zone,omitempty" toml:"time_zone,omitempty"`             // 时区，如 Asia/Shanghai，为空使用系统时区
+	PassScore       int                 `json:"pass_score" toml:"pass_score"`                               // 及格线（百分比），低于及格线只能获得参与奖
+	DefaultLowStock int                 `json:"default_low_stock" toml:"default_low_stock"`                 // 默认低库存预警阈值
+	Participation   ParticipationConfig `json:"participation" toml:"participation"`
+	Storage         StorageConfig       `json:"storage" toml:"storage"`
+	Branding        BrandingConfig      `json:"branding" toml:"branding"`
+	Schedule        ScheduleConfig      `json:"schedule" toml:"schedule"`
+	Report          ReportConfig        `json:"report" toml:"report"`
+	RateLimit       RateLimitConfig     `json:"rate_limit" toml:"rate_limit"`
+	Log             LogConfig           `json:"log" toml:"log"`
+	PrizeLevels     []PrizeLevel        `json:"prize_levels" toml:"prize_levels"`
+}
+
+// ParticipationConfig 参与限制
+type ParticipationConfig struct {
+	Policy    string `json:"policy" toml:"policy"`           // daily / unlimited
+	MaxPerDay int    `json:"max_per_day" toml:"max_per_day"` // 每天最多答题次数
+}
+
+// StorageConfig 结果存储
+type StorageConfig struct {
+	Backend string `json:"backend" toml:"backend"`               // xlsx / jsonl
+	Path    string `json:"path,omitempty" toml:"path,omitempty"` // 结果文件，为空时使用数据目录下的 records.xlsx / records.jsonl
+}
+
+// BrandingConfig 页面与管理后台显示的名称
+type BrandingConfig struct {
+	Title        string `json:"title" toml:"title"`               // 答题页面标题
+	Organization string `json:"organization" toml:"organization"` // 首页顶部的机构名称
+	AdminTitle   string `json:"admin_title" toml:"admin_title"`   // 管理后台窗口标题
+}
+
+var (
+	// passScore 及格线（百分比），调用方需持有 mutex
+	passScore = 0
+	// questionBankPath、codesPath 最近一次加载的题库和兑换码文件，调用方需持有 mutex
+	questionBankPath string
+	codesPath        string
+	// participation 参与限制，调用方需持有 mutex
+	participation = ParticipationConfig{Policy: ParticipationDaily, MaxPerDay: 1}
+	// branding 页面显示名称，调用方需持有 mutex
+	branding = defaultBranding()
+	// timeZoneName 配置的时区名称，调用方需持有 mutex
+	timeZoneName string
+	// eventConfigFile 指定的活动配置文件（只读取，不写回），启动时再以数据目录下 event.json 中的修改覆盖
+	eventConfigFile string
+	// keepConfigFileValues 保存运行状态前调用，将命令行参数临时覆盖的项恢复为配置文件中的值，
+	// 避免 -addr 等参数被当作配置保存下来，调用方需持有 mutex
+	keepConfigFileValues func(cfg *EventConfig)
+)
+
+// defaultBranding 默认显示名称
+func defaultBranding() BrandingConfig {
+	return BrandingConfig{
+		Title:        "反诈知识答题",
+		Organization: "国家反诈中心",
+		AdminTitle:   "反诈答题 管理后台 by-杨典",
+	}
+}
+
+// currentBranding 当前的页面显示名称
+func currentBranding() BrandingConfig {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return branding
+}
+
+// defaultEventConfig 没有配置文件时使用的默认配置
+func defaultEventConfig() EventConfig {
+	return EventConfig{
+		ListenAddr:      ":8080",
+		DefaultLowStock: 5,
+		Participation:   ParticipationConfig{Policy: ParticipationDaily, MaxPerDay: 1},
+		Storage:         StorageConfig{Backend: StorageXlsx},
+		Branding:        defaultBranding(),
+	}
+}
+
+// eventStatePath 保存运行中修改的配置文件位置，即数据目录下的 event.json
+func eventStatePath() string {
+	return filepath.Join(dataDir, "event.json")
+}
+
+// isTOMLPath 按扩展名判断配置文件格式
+func isTOMLPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// LoadEventConfig 读取活动配置，未写出的项使用默认值
+func LoadEventConfig(path string) (EventConfig, error) {
+	cfg := defaultEventConfig()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if isTOMLPath(path) {
+		err = toml.Unmarshal(b, &cfg)
+	} else {
+		err = json.Unmarshal(b, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("活动配置格式错误: %v", err)
+	}
+	return cfg, nil
+}
+
+// encodeEventConfig 将活动配置编码为 TOML 或 JSON
+func encodeEventConfig(cfg EventConfig, asTOML bool) ([]byte, error) {
+	if asTOML {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// SaveEventConfig 保存活动配置，先写临时文件再替换，避免写到一半的文件。
+// 配置中有邮箱密码和监控令牌，文件只允许本账号读写
+func SaveEventConfig(path string, cfg EventConfig) error {
+	b, err := encodeEventConfig(cfg, isTOMLPath(path))
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	// 临时文件已存在时 WriteFile 不会修改其权限
+	if err := os.Chmod(tmp, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// currentEventConfigLocked 当前生效的活动配置，调用方需持有 mutex
+func currentEventConfigLocked() EventConfig {
+	levels := make([]PrizeLevel, len(prizeLevels))
+	copy(levels, prizeLevels)
+	rateMu.Lock()
+	limits := rateLimit
+	rateMu.Unlock()
+	return EventConfig{
+		QuestionBank:    questionBankPath,
+		CodesFile:       codesPath,
+		ListenAddr:      listenAddr,
+		ListenFallback:  listenFallback,
+		TLS:             tlsEnabled,
+		MDNSName:        mdnsName,
+		AdvertiseHost:   advertiseHost,
+		CaptivePortal:   captivePortal,
+		MetricsToken:    metricsToken,
+		TimeZone:        timeZoneName,
+		PassScore:       passScore,
+		DefaultLowStock: defaultLowStock,
+		Participation:   participation,
+		Storage:         StorageConfig{Backend: storageBackend, Path: resultsFile},
+		Branding:        branding,
+		Schedule:        schedule,
+		Report:          reportConfig,
+		RateLimit:       limits,
+		Log:             currentLogConfig(),
+		PrizeLevels:     levels,
+	}
+}
+
+// normalizePrizeLevels 校验奖品规则并补全默认值，按所需分数从高到低排序（参与奖放在最后）
+func normalizePrizeLevels(levels []PrizeLevel) ([]PrizeLevel, error) {
+	out := make([]PrizeLevel, 0, len(levels))
+	seen := map[string]bool{}
+	for i, l := range levels {
+		l.Level = strings.TrimSpace(l.Level)
+		l.Prize = strings.TrimSpace(l.Prize)
+		if l.Level == "" {
+			return nil, fmt.Errorf("第 %d 个奖品等级名称为空", i+1)
+		}
+		if seen[l.Level] {
+			return nil, fmt.Errorf("奖品等级 %s 重复", l.Level)
+		}
+		seen[l.Level] = true
+		if l.Score < 0 || l.Score > 100 {
+			return nil, fmt.Errorf("奖品等级 %s 的所需分数应在 0~100 之间", l.Level)
+		}
+		if l.Stock < 0 || l.LowStock < 0 {
+			return nil, fmt.Errorf("奖品等级 %s 的库存和预警阈值不能为负数", l.Level)
+		}
+		switch l.Kind {
+		case "":
+			l.Kind = PrizeKindCode
+		case PrizeKindCode, PrizeKindConsolation:
+		case PrizeKindItem:
+			if l.Stock == 0 {
+				return nil, fmt.Errorf("实物奖品 %s 需要填写库存数量", l.Level)
+			}
+		default:
+			return nil, fmt.Errorf("奖品等级 %s 的类型 %q 无效", l.Level, l.Kind)
+		}
+		switch l.Fallback {
+		case "":
+			l.Fallback = FallbackDowngrade
+		case FallbackDowngrade, FallbackConsolation, FallbackNone:
+		default:
+			return nil, fmt.Errorf("奖品等级 %s 的发完处理方式 %q 无效", l.Level, l.Fallback)
+		}
+		out = append(out, l)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ci, cj := out[i].Kind == PrizeKindConsolation, out[j].Kind == PrizeKindConsolation
+		if ci != cj {
+			return cj
+		}
+		return out[i].Score > out[j].Score
+	})
+	return out, nil
+}
+
+// applyPrizeRules 校验并替换奖品规则和及格线，之后提交的答卷立即使用新规则，并保存到活动配置
+func applyPrizeRules(levels []PrizeLevel, pass int) error {
+	if pass < 0 || pass > 100 {
+		return fmt.Errorf("及格线应在 0~100 之间")
+	}
+	levels, err := normalizePrizeLevels(levels)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	prizeLevels = levels
+	passScore = pass
+	resetInventoryAlerts()
+	if onInventoryChange != nil {
+		onInventoryChange()
+	}
+	mutex.Unlock()
+
+	return saveCurrentEventConfig()
+}
+
+// applyEventConfig 校验并应用活动配置（题库和兑换码由 loadConfiguredSources 加载）
+func applyEventConfig(cfg EventConfig) error {
+	if cfg.PassScore < 0 || cfg.PassScore > 100 {
+		return fmt.Errorf("及格线应在 0~100 之间")
+	}
+	levels, err := normalizePrizeLevels(cfg.PrizeLevels)
+	if err != nil {
+		return err
+	}
+	loc := time.Local
+	if cfg.TimeZone != "" {
+		if loc, err = time.LoadLocation(cfg.TimeZone); err != nil {
+			return fmt.Errorf("时区 %q 无效: %v", cfg.TimeZone, err)
+		}
+	}
+	switch cfg.Participation.Policy {
+	case "":
+		cfg.Participation.Policy = ParticipationDaily
+	case ParticipationDaily, ParticipationUnlimited:
+	default:
+		return fmt.Errorf("参与限制 %q 无效", cfg.Participation.Policy)
+	}
+	if cfg.Participation.MaxPerDay <= 0 {
+		cfg.Participation.MaxPerDay = 1
+	}
+	switch cfg.Storage.Backend {
+	case "":
+		cfg.Storage.Backend = StorageXlsx
+	case StorageXlsx, StorageJSONL:
+	default:
+		return fmt.Errorf("结果存储方式 %q 无效", cfg.Storage.Backend)
+	}
+	if err := validateMDNSName(cfg.MDNSName); err != nil {
+		return err
+	}
+	if err := validateAdvertiseHost(cfg.AdvertiseHost); err != nil {
+		return err
+	}
+	if err := validateSchedule(cfg.Schedule); err != nil {
+		return err
+	}
+	if err := validateReportConfig(cfg.Report); err != nil {
+		return err
+	}
+	if err := validateRateLimit(cfg.RateLimit); err != nil {
+		return err
+	}
+	if err := validateLogConfig(cfg.Log); err != nil {
+		return err
+	}
+	def := defaultBranding()
+	if cfg.Branding.Title == "" {
+		cfg.Branding.Title = def.Title
+	}
+	if cfg.Branding.Organization == "" {
+		cfg.Branding.Organization = def.Organization
+	}
+	if cfg.Branding.AdminTitle == "" {
+		cfg.Branding.AdminTitle = def.AdminTitle
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if cfg.DataDir != "" {
+		dataDir = cfg.DataDir
+	}
+	if cfg.ListenAddr != "" {
+		listenAddr = cfg.ListenAddr
+	}
+	listenFallback = cfg.ListenFallback
+	tlsEnabled = cfg.TLS
+	mdnsName = cfg.MDNSName
+	advertiseHost = cfg.AdvertiseHost
+	captivePortal = cfg.CaptivePortal
+	metricsToken = cfg.MetricsToken
+	rateMu.Lock()
+	rateLimit = cfg.RateLimit
+	rateMu.Unlock()
+	logConfig.Store(&cfg.Log)
+	prizeLevels = levels
+	passScore = cfg.PassScore
+	if cfg.DefaultLowStock > 0 {
+		defaultLowStock = cfg.DefaultLowStock
+	}
+	participation = cfg.Participation
+	storageBackend = cfg.Storage.Backend
+	resultsFile = cfg.Storage.Path
+	branding = cfg.Branding
+	timeZoneName = cfg.TimeZone
+	eventLocation = loc
+	schedule = cfg.Schedule
+	setReportConfigLocked(cfg.Report)
+	invalidateRecordsLocked()
+	resetDashboardLocked()
+	resetInventoryAlerts()
+	if onInventoryChange != nil {
+		onInventoryChange()
+	}
+	return nil
+}
+
+// loadConfiguredSources 加载配置中的题库和兑换码，配置中已有奖品规则时保留规则只加载兑换码
+func loadConfiguredSources(cfg EventConfig) error {
+	if cfg.QuestionBank != "" {
+		if _, err := loadQuestionBank(cfg.QuestionBank); err != nil {
+			return fmt.Errorf("加载题库错误: %v", err)
+		}
+	}
+	if cfg.CodesFile != "" {
+		if _, _, err := loadPrizeCodes(cfg.CodesFile, len(cfg.PrizeLevels) > 0); err != nil {
+			return fmt.Errorf("加载兑换码错误: %v", err)
+		}
+	}
+	return nil
+}
+
+// saveCurrentEventConfig 将当前生效的配置写入数据目录下的 event.json（命令行参数覆盖的项保持配置文件中的值）
+func saveCurrentEventConfig() error {
+	mutex.Lock()
+	cfg := currentEventConfigLocked()
+	if keepConfigFileValues != nil {
+		keepConfigFileValues(&cfg)
+	}
+	path := eventStatePath()
+	mutex.Unlock()
+	return SaveEventConfig(path, cfg)
+}
+
+// restoreEventConfig 启动时读取活动配置并应用：先读取 -config 指定的配置文件，
+// 再以数据目录下 event.json 中保存的运行中修改覆盖，文件都不存在时保持默认。
+// dir 为命令行指定的数据目录，优先于配置文件中的 data_dir。
+// 题库和兑换码由调用方按返回的配置加载。
+func restoreEventConfig(dir string) (EventConfig, error) {
+	cfg := defaultEventConfig()
+	if eventConfigFile != "" {
+		c, err := LoadEventConfig(eventConfigFile)
+		if err != nil && !os.IsNotExist(err) {
+			return c, err
+		}
+		if err == nil {
+			cfg = c
+		}
+	}
+	if dir != "" {
+		cfg.DataDir = dir
+	}
+	stateDir := cfg.DataDir
+	if stateDir == "" {
+		stateDir = dataDir
+	}
+	state := filepath.Join(stateDir, "event.json")
+	if state != eventConfigFile {
+		b, err := os.ReadFile(state)
+		if err != nil && !os.IsNotExist(err) {
+			return cfg, err
+		}
+		// event.json 中没有的项（如 data_dir）保持配置文件中的值
+		if err == nil {
+			if err := json.Unmarshal(b, &cfg); err != nil {
+				return cfg, fmt.Errorf("%s 格式错误: %v", state, err)
+			}
+		}
+	}
+	return cfg, applyEventConfig(cfg)
+}
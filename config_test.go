@@ -0,0 +1,245 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// sampleEventConfig 各部分都有内容的活动配置
+func sampleEventConfig() EventConfig {
+	cfg := defaultEventConfig()
+	cfg.QuestionBank = "question.xlsx"
+	cfg.CodesFile = "codes.xlsx"
+	cfg.TLS = true
+	cfg.MDNSName = "quiz-branch01"
+	cfg.MetricsToken = "secret"
+	cfg.TimeZone = "Asia/Shanghai"
+	cfg.PassScore = 60
+	cfg.Participation = ParticipationConfig{Policy: ParticipationUnlimited, MaxPerDay: 3}
+	cfg.Storage = StorageConfig{Backend: StorageJSONL, Path: "records.jsonl"}
+	cfg.Schedule = ScheduleConfig{StartDate: "2026-10-01", EndDate: "2026-10-07", OpenTime: "09:00", CloseTime: "17:30"}
+	cfg.Report = ReportConfig{Time: "18:00", SMTP: SMTPConfig{Addr: "127.0.0.1:25", From: "quiz@example.com", To: []string{"a@example.com"}, Password: "pw"}}
+	cfg.RateLimit = RateLimitConfig{Endpoints: map[string]RateRule{"/api/submit": {PerMinute: 3, Burst: 2}}, BanAfter: 10}
+	cfg.Log = LogConfig{MaxSizeMB: 5, AccessKeep: 3}
+	cfg.PrizeLevels = []PrizeLevel{
+		{Level: "一等奖", Score: 100, Kind: PrizeKindCode, Fallback: FallbackDowngrade},
+		{Level: "纪念品", Score: 80, Kind: PrizeKindItem, Stock: 20, Prize: "雨伞", Fallback: FallbackConsolation},
+		{Level: "参与奖", Kind: PrizeKindConsolation, Prize: "参与证书", Fallback: FallbackNone},
+	}
+	return cfg
+}
+
+func TestEventConfigRoundTrip(t *testing.T) {
+	for _, name := range []string{"event.toml", "event.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			want := sampleEventConfig()
+			if err := SaveEventConfig(path, want); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadEventConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("LoadEventConfig() = %+v, want %+v", got, want)
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := fi.Mode().Perm(); mode != 0600 {
+				t.Fatalf("config file mode = %o, want 600", mode)
+			}
+		})
+	}
+}
+
+func TestSaveEventConfigTightensMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	// 上次中断留下的临时文件权限较宽
+	if err := os.WriteFile(path+".tmp", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveEventConfig(path, defaultEventConfig()); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Fatalf("config file mode = %o, want 600", mode)
+	}
+}
+
+func TestApplyEventConfigValidation(t *testing.T) {
+	keepEventConfig(t)
+	tests := []struct {
+		name string
+		edit func(c *EventConfig)
+		ok   bool
+	}{
+		{"sample", func(c *EventConfig) {}, true},
+		{"pass score too high", func(c *EventConfig) { c.PassScore = 101 }, false},
+		{"negative pass score", func(c *EventConfig) { c.PassScore = -1 }, false},
+		{"bad participation", func(c *EventConfig) { c.Participation.Policy = "weekly" }, false},
+		{"bad storage backend", func(c *EventConfig) { c.Storage.Backend = "csv" }, false},
+		{"bad time zone", func(c *EventConfig) { c.TimeZone = "Mars/Olympus" }, false},
+		{"bad schedule", func(c *EventConfig) { c.Schedule.CloseTime = "08:00" }, false},
+		{"bad rate limit", func(c *EventConfig) { c.RateLimit.BanMinutes = -1 }, false},
+		{"duplicate prize level", func(c *EventConfig) { c.PrizeLevels[1].Level = "一等奖" }, false},
+		{"item without stock", func(c *EventConfig) { c.PrizeLevels[1].Stock = 0 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := sampleEventConfig()
+			tt.edit(&cfg)
+			if err := applyEventConfig(cfg); (err == nil) != tt.ok {
+				t.Fatalf("applyEventConfig() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestApplyEventConfigDefaults(t *testing.T) {
+	keepEventConfig(t)
+	cfg := sampleEventConfig()
+	cfg.Participation = ParticipationConfig{}
+	cfg.Storage.Backend = ""
+	cfg.Branding = BrandingConfig{Title: "防骗答题"}
+	cfg.PrizeLevels = []PrizeLevel{{Level: "参与奖", Kind: PrizeKindConsolation}, {Level: "一等奖", Score: 90}}
+	if err := applyEventConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	got := currentEventConfigLocked()
+	mutex.Unlock()
+	if got.Participation != (ParticipationConfig{Policy: ParticipationDaily, MaxPerDay: 1}) {
+		t.Fatalf("participation = %+v", got.Participation)
+	}
+	if got.Storage.Backend != StorageXlsx {
+		t.Fatalf("storage backend = %q", got.Storage.Backend)
+	}
+	if got.Branding.Title != "防骗答题" || got.Branding.Organization != defaultBranding().Organization {
+		t.Fatalf("branding = %+v", got.Branding)
+	}
+	if got.PrizeLevels[0].Level != "一等奖" || got.PrizeLevels[0].Kind != PrizeKindCode || got.PrizeLevels[0].Fallback != FallbackDowngrade {
+		t.Fatalf("prize levels = %+v", got.PrizeLevels)
+	}
+}
+
+func TestSaveCurrentEventConfig(t *testing.T) {
+	keepEventConfig(t)
+	if err := applyEventConfig(sampleEventConfig()); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	fileCfg := filepath.Join(dir, "event.toml")
+	if err := os.WriteFile(fileCfg, []byte("pass_score = 60\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	dataDir = filepath.Join(dir, "data")
+	eventConfigFile = fileCfg
+	listenAddr = ":9999" // 模拟命令行参数覆盖
+	keepConfigFileValues = func(cfg *EventConfig) { cfg.ListenAddr = ":8080" }
+	mutex.Unlock()
+
+	if err := saveCurrentEventConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(fileCfg); string(b) != "pass_score = 60\n" {
+		t.Fatalf("config file was rewritten: %q", b)
+	}
+	got, err := LoadEventConfig(filepath.Join(dir, "data", "event.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ListenAddr != ":8080" {
+		t.Fatalf("saved listen_addr = %q, want value from the config file", got.ListenAddr)
+	}
+	if got.PassScore != 60 || got.Storage.Backend != StorageJSONL {
+		t.Fatalf("saved config = %+v", got)
+	}
+}
+
+func TestRestoreEventConfigAppliesSavedChanges(t *testing.T) {
+	keepEventConfig(t)
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	fileCfg := filepath.Join(dir, "event.toml")
+	toml := "data_dir = " + strconv.Quote(data) + "\nlisten_addr = \":7000\"\npass_score = 50\n\n[branding]\ntitle = \"配置标题\"\n"
+	if err := os.WriteFile(fileCfg, []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 上次运行中在管理后台修改了及格线
+	if err := SaveEventConfig(filepath.Join(data, "event.json"), EventConfig{PassScore: 70, Branding: BrandingConfig{Title: "配置标题"}}); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+
+	tests := []struct {
+		name    string
+		file    string
+		dir     string
+		pass    int
+		addr    string
+		dataDir string
+	}{
+		{"config file and saved changes", fileCfg, "", 70, ":7000", data},
+		{"data dir flag without saved changes", fileCfg, other, 50, ":7000", other},
+		{"saved changes only", "", data, 70, ":8080", data},
+		{"missing config file", filepath.Join(dir, "missing.toml"), other, 0, ":8080", other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex.Lock()
+			eventConfigFile = tt.file
+			mutex.Unlock()
+			cfg, err := restoreEventConfig(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mutex.Lock()
+			pass, title, dd := passScore, branding.Title, dataDir
+			mutex.Unlock()
+			if pass != tt.pass || cfg.PassScore != tt.pass {
+				t.Fatalf("pass score = %d (returned %d), want %d", pass, cfg.PassScore, tt.pass)
+			}
+			if cfg.ListenAddr != tt.addr {
+				t.Fatalf("listen_addr = %q, want %q", cfg.ListenAddr, tt.addr)
+			}
+			if dd != tt.dataDir {
+				t.Fatalf("dataDir = %q, want %q", dd, tt.dataDir)
+			}
+			if tt.pass != 0 && title != "配置标题" {
+				t.Fatalf("branding title = %q", title)
+			}
+		})
+	}
+	if b, _ := os.ReadFile(fileCfg); string(b) != toml {
+		t.Fatalf("config file was rewritten: %q", b)
+	}
+}
+
+// keepEventConfig 测试结束后恢复当前的活动配置，以及测试中会直接修改的
+// dataDir、eventConfigFile、listenAddr 和 keepConfigFileValues
+func keepEventConfig(t *testing.T) {
+	t.Helper()
+	mutex.Lock()
+	saved := currentEventConfigLocked()
+	savedDir, savedFile, savedAddr, savedKeep := dataDir, eventConfigFile, listenAddr, keepConfigFileValues
+	mutex.Unlock()
+	t.Cleanup(func() {
+		if err := applyEventConfig(saved); err != nil {
+			t.Error(err)
+		}
+		mutex.Lock()
+		dataDir, eventConfigFile, listenAddr, keepConfigFileValues = savedDir, savedFile, savedAddr, savedKeep
+		mutex.Unlock()
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// methodNotAllowed 返回 405 和允许的请求方法
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// allowMethod 请求方法在 methods 中时返回 true（允许 GET 时也允许 HEAD），否则返回 405
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m || m == http.MethodGet && r.Method == http.MethodHead {
+			return true
+		}
+	}
+	methodNotAllowed(w, methods...)
+	return false
+}
+
+// isSafeMethod 不修改数据的请求方法
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin 请求是否来自本站页面：浏览器发出的请求带有 Origin（或 Referer），须与请求的主机一致；
+// 两者都没有的请求来自脚本或命令行工具，不会带上浏览器中的登录状态，允许通过
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	src := r.Header.Get("Origin")
+	if src == "" {
+		src = r.Header.Get("Referer")
+	}
+	if src == "" {
+		return true
+	}
+	u, err := url.Parse(src)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// sameOriginHandler 拒绝其它网站发起的 POST、DELETE 等修改请求
+func sameOriginHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isSafeMethod(r.Method) && !sameOrigin(r) {
+			writeError(w, "不允许从其它网站提交", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSameOriginHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"same origin", http.MethodPost, map[string]string{"Origin": "http://192.168.1.5:8080"}, http.StatusNoContent},
+		{"same origin referer", http.MethodPost, map[string]string{"Referer": "http://192.168.1.5:8080/quiz.html"}, http.StatusNoContent},
+		{"no origin", http.MethodPost, nil, http.StatusNoContent},
+		{"other origin", http.MethodPost, map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"other port", http.MethodDelete, map[string]string{"Origin": "http://192.168.1.5:9090"}, http.StatusForbidden},
+		{"other referer", http.MethodPost, map[string]string{"Referer": "https://evil.example/x"}, http.StatusForbidden},
+		{"null origin", http.MethodPost, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"cross-site fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"cross-site get", http.MethodGet, map[string]string{"Origin": "http://evil.example"}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://192.168.1.5:8080/api/submit", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			sameOriginHandler(next).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowMethod(t *testing.T) {
+	tests := []struct {
+		method  string
+		allowed []string
+		ok      bool
+	}{
+		{http.MethodGet, []string{http.MethodGet}, true},
+		{http.MethodHead, []string{http.MethodGet}, true},
+		{http.MethodPost, []string{http.MethodGet}, false},
+		{http.MethodDelete, []string{http.MethodGet, http.MethodPost}, false},
+		{http.MethodPost, []string{http.MethodGet, http.MethodPost}, true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		ok := allowMethod(w, httptest.NewRequest(tt.method, "/api/status", nil), tt.allowed...)
+		if ok != tt.ok {
+			t.Fatalf("allowMethod(%s, %v) = %v, want %v", tt.method, tt.allowed, ok, tt.ok)
+		}
+		if ok {
+			continue
+		}
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body["error"] == "" {
+			t.Fatalf("405 body = %v, %v, want JSON error", body, err)
+		}
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" || w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("allowMethod(%s) = %d, Allow %q", tt.method, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
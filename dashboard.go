@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// dashboardMinutes 实时看板显示最近多少分钟的每分钟提交数
+const dashboardMinutes = 30
+
+// DashboardSnapshot 实时看板数据（今天）
+type DashboardSnapshot struct {
+	Day       string         `json:"day"`
+	Started   int            `json:"started"`    // 开始答题（领取题目）次数
+	Submitted int            `json:"submitted"`  // 提交答卷次数
+	Histogram []int          `json:"histogram"`  // 得分百分比分布，每 10 分一档，最后一档为满分
+	Prizes    map[string]int `json:"prizes"`     // 各奖品等级已发放数量
+	PerMinute []MinuteCount  `json:"per_minute"` // 最近 30 分钟每分钟提交数
+}
+
+// MinuteCount 某一分钟的提交数
+type MinuteCount struct {
+	Minute string `json:"minute"`
+	Count  int    `json:"count"`
+}
+
+// dashboardState 看板计数，提交答卷时增量更新
+type dashboardState struct {
+	day       string
+	started   int
+	submitted int
+	histogram [11]int
+	prizes    map[string]int
+	perMinute map[int64]int // 按 Unix 分钟计数
+}
+
+var (
+	// dashboard 今天的看板计数，首次使用或跨天时根据结果文件重建，调用方需持有 mutex
+	dashboard *dashboardState
+	// dashboardSubs 看板订阅者（网页 SSE 连接和桌面看板窗口），调用方需持有 mutex
+	dashboardSubs = map[chan DashboardSnapshot]struct{}{}
+)
+
+// histogramBucket 得分百分比所在的分档
+func histogramBucket(percentage int) int {
+	if percentage < 0 {
+		return 0
+	}
+	if percentage > 100 {
+		return 10
+	}
+	return percentage / 10
+}
+
+// countSubmissionLocked 将一条答题记录计入看板，调用方需持有 mutex
+func (d *dashboardState) countSubmissionLocked(rec ResultRecord) {
+	d.submitted++
+	percentage := 0
+	if rec.Total > 0 {
+		percentage = rec.Score * 100 / rec.Total
+	}
+	d.histogram[histogramBucket(percentage)]++
+	if rec.Award.Level != "" {
+		d.prizes[rec.Award.Level]++
+	}
+	d.perMinute[rec.Time.Unix()/60]++
+}
+
+// dashboardLocked 今天的看板计数，调用方需持有 mutex。
+// 重建时开始答题次数按已提交次数计算（重启前领取题目但未提交的次数无法恢复）。
+func dashboardLocked() *dashboardState {
+	day := eventNow().Format("2006-01-02")
+	if dashboard != nil && dashboard.day == day {
+		return dashboard
+	}
+	d := &dashboardState{day: day, prizes: map[string]int{}, perMinute: map[int64]int{}}
+	recs, err := recordsLocked()
+	if err != nil {
+		log.Printf("读取答题结果错误: %v", err)
+	}
+	for _, r := range recs {
+		if sameEventDay(r.Time) {
+			d.countSubmissionLocked(r)
+		}
+	}
+	d.started = d.submitted
+	dashboard = d
+	return d
+}
+
+// resetDashboardLocked 结果文件或时区变化后重建看板，调用方需持有 mutex
+func resetDashboardLocked() {
+	dashboard = nil
+	publishDashboardLocked()
+}
+
+// recordStartLocked 记录一次开始答题，调用方需持有 mutex
+func recordStartLocked() {
+	dashboardLocked().started++
+	publishDashboardLocked()
+}
+
+// recordSubmissionLocked 记录一次提交，调用方需持有 mutex
+func recordSubmissionLocked(rec ResultRecord) {
+	d := dashboardLocked()
+	d.countSubmissionLocked(rec)
+	if d.started < d.submitted {
+		d.started = d.submitted
+	}
+	publishDashboardLocked()
+}
+
+// dashboardSnapshotLocked 看板数据快照，调用方需持有 mutex
+func dashboardSnapshotLocked() DashboardSnapshot {
+	d := dashboardLocked()
+	s := DashboardSnapshot{
+		Day:       d.day,
+		Started:   d.started,
+		Submitted: d.submitted,
+		Histogram: append([]int(nil), d.histogram[:]...),
+		Prizes:    map[string]int{},
+		PerMinute: make([]MinuteCount, 0, dashboardMinutes),
+	}
+	for k, v := range d.prizes {
+		s.Prizes[k] = v
+	}
+	now := eventNow()
+	cur := now.Unix() / 60
+	for m := cur - dashboardMinutes + 1; m <= cur; m++ {
+		s.PerMinute = append(s.PerMinute, MinuteCount{
+			Minute: time.Unix(m*60, 0).In(now.Location()).Format("15:04"),
+			Count:  d.perMinute[m],
+		})
+	}
+	return s
+}
+
+// dashboardSnapshot 看板数据快照
+func dashboardSnapshot() DashboardSnapshot {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return dashboardSnapshotLocked()
+}
+
+// publishDashboardLocked 向所有订阅者推送最新数据，订阅者来不及接收时只保留最新一份，调用方需持有 mutex
+func publishDashboardLocked() {
+	if len(dashboardSubs) == 0 {
+		return
+	}
+	s := dashboardSnapshotLocked()
+	for ch := range dashboardSubs {
+		select {
+		case ch <- s:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- s:
+			default:
+			}
+		}
+	}
+}
+
+// subscribeDashboard 订阅看板更新，返回的函数用于取消订阅
+func subscribeDashboard() (<-chan DashboardSnapshot, func()) {
+	ch := make(chan DashboardSnapshot, 1)
+	mutex.Lock()
+	dashboardSubs[ch] = struct{}{}
+	mutex.Unlock()
+	return ch, func() {
+		mutex.Lock()
+		delete(dashboardSubs, ch)
+		mutex.Unlock()
+	}
+}
+
+// formatHistogramLabel 分档名称，如 "60-69"、"100"
+func formatHistogramLabel(bucket int) string {
+	if bucket >= 10 {
+		return "100"
+	}
+	return fmt.Sprintf("%d-%d", bucket*10, bucket*10+9)
+}
+
+// serveDashboardEvents 以 SSE 推送看板数据：连接时先发送一次，之后每次提交推送，
+// 每 15 秒再发送一次用于刷新每分钟统计并保持连接
+func serveDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch, cancel := subscribeDashboard()
+	defer cancel()
+
+	send := func(s DashboardSnapshot) bool {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", b); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send(dashboardSnapshot()) {
+		return
+	}
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case s := <-ch:
+			if !send(s) {
+				return
+			}
+		case <-ticker.C:
+			if !send(dashboardSnapshot()) {
+				return
+			}
+		}
+	}
+}
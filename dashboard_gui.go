@@ -0,0 +1,115 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showDashboardWindow 实时看板：开始/提交人数、得分分布、各等级发放数量和每分钟提交数，随提交实时刷新
+func showDashboardWindow(a fyne.App) {
+	win := a.NewWindow("实时看板")
+	win.Resize(fyne.NewSize(560, 620))
+
+	started := widget.NewLabel("")
+	submitted := widget.NewLabel("")
+	prizes := widget.NewLabel("")
+	perMinute := widget.NewLabel("")
+	perMinute.Wrapping = fyne.TextWrapWord
+
+	bars := make([]*widget.ProgressBar, 11)
+	counts := make([]*widget.Label, 11)
+	histogram := container.NewGridWithColumns(3)
+	for i := range bars {
+		bars[i] = widget.NewProgressBar()
+		bars[i].TextFormatter = func() string { return "" }
+		counts[i] = widget.NewLabel("0")
+		histogram.Add(widget.NewLabel(formatHistogramLabel(i)))
+		histogram.Add(bars[i])
+		histogram.Add(counts[i])
+	}
+
+	render := func(s DashboardSnapshot) {
+		started.SetText(fmt.Sprintf("开始答题: %d", s.Started))
+		submitted.SetText(fmt.Sprintf("已提交: %d", s.Submitted))
+
+		max := 1
+		for _, n := range s.Histogram {
+			if n > max {
+				max = n
+			}
+		}
+		for i, n := range s.Histogram {
+			bars[i].SetValue(float64(n) / float64(max))
+			counts[i].SetText(fmt.Sprint(n))
+		}
+
+		levels := make([]string, 0, len(s.Prizes))
+		for l := range s.Prizes {
+			levels = append(levels, l)
+		}
+		sort.Strings(levels)
+		lines := []string{}
+		for _, l := range levels {
+			lines = append(lines, fmt.Sprintf("%s: %d", l, s.Prizes[l]))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "暂无")
+		}
+		prizes.SetText(strings.Join(lines, "\n"))
+
+		// 只显示最近 10 分钟
+		recent := s.PerMinute
+		if len(recent) > 10 {
+			recent = recent[len(recent)-10:]
+		}
+		parts := []string{}
+		for _, m := range recent {
+			parts = append(parts, fmt.Sprintf("%s %d", m.Minute, m.Count))
+		}
+		perMinute.SetText(strings.Join(parts, "   "))
+	}
+	render(dashboardSnapshot())
+
+	ch, cancel := subscribeDashboard()
+	done := make(chan struct{})
+	go func() {
+		// 没有新提交时也定时刷新，让每分钟提交数随时间滚动
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case s := <-ch:
+				fyne.Do(func() { render(s) })
+			case <-ticker.C:
+				s := dashboardSnapshot()
+				fyne.Do(func() { render(s) })
+			}
+		}
+	}()
+	win.SetOnClosed(func() {
+		cancel()
+		close(done)
+	})
+
+	bold := fyne.TextStyle{Bold: true}
+	win.SetContent(container.NewVScroll(container.NewVBox(
+		container.NewHBox(started, submitted),
+		widget.NewLabelWithStyle("得分分布 (%)", fyne.TextAlignLeading, bold),
+		histogram,
+		widget.NewLabelWithStyle("已发放奖品", fyne.TextAlignLeading, bold),
+		prizes,
+		widget.NewLabelWithStyle("每分钟提交数", fyne.TextAlignLeading, bold),
+		perMinute,
+	)))
+	win.Show()
+}
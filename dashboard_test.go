@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramBucket(t *testing.T) {
+	tests := []struct{ percentage, want int }{
+		{-5, 0}, {0, 0}, {9, 0}, {10, 1}, {59, 5}, {99, 9}, {100, 10}, {120, 10},
+	}
+	for _, tt := range tests {
+		if got := histogramBucket(tt.percentage); got != tt.want {
+			t.Errorf("histogramBucket(%d) = %d, want %d", tt.percentage, got, tt.want)
+		}
+	}
+	if got := formatHistogramLabel(6); got != "60-69" {
+		t.Errorf("formatHistogramLabel(6) = %q", got)
+	}
+	if got := formatHistogramLabel(10); got != "100" {
+		t.Errorf("formatHistogramLabel(10) = %q", got)
+	}
+}
+
+func TestDashboardCounts(t *testing.T) {
+	mutex.Lock()
+	saved := dashboard
+	now := eventNow()
+	dashboard = &dashboardState{day: now.Format("2006-01-02"), prizes: map[string]int{}, perMinute: map[int64]int{}}
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dashboard = saved
+		mutex.Unlock()
+	})
+
+	ch, cancel := subscribeDashboard()
+	defer cancel()
+
+	mutex.Lock()
+	recordStartLocked()
+	recordStartLocked()
+	recordStartLocked()
+	recordSubmissionLocked(ResultRecord{Time: now, Score: 10, Total: 10, Award: Award{Level: "一等奖"}})
+	recordSubmissionLocked(ResultRecord{Time: now.Add(-5 * time.Minute), Score: 6, Total: 10})
+	recordSubmissionLocked(ResultRecord{Time: now.Add(-time.Hour), Score: 0, Total: 0})
+	mutex.Unlock()
+
+	// 订阅者来不及接收时只保留最新一份
+	var s DashboardSnapshot
+	select {
+	case s = <-ch:
+	default:
+		t.Fatal("no dashboard update published")
+	}
+	select {
+	case extra := <-ch:
+		t.Fatalf("stale update left in channel: %+v", extra)
+	default:
+	}
+
+	if s.Started != 3 || s.Submitted != 3 {
+		t.Fatalf("started/submitted = %d/%d, want 3/3", s.Started, s.Submitted)
+	}
+	if s.Histogram[10] != 1 || s.Histogram[6] != 1 || s.Histogram[0] != 1 {
+		t.Fatalf("histogram = %v", s.Histogram)
+	}
+	if len(s.Prizes) != 1 || s.Prizes["一等奖"] != 1 {
+		t.Fatalf("prizes = %v", s.Prizes)
+	}
+	if len(s.PerMinute) != dashboardMinutes {
+		t.Fatalf("per-minute entries = %d, want %d", len(s.PerMinute), dashboardMinutes)
+	}
+	// 一小时前的提交不在最近 30 分钟内
+	total := 0
+	for _, m := range s.PerMinute {
+		total += m.Count
+	}
+	if total != 2 {
+		t.Fatalf("per-minute = %+v", s.PerMinute)
+	}
+
+	// 快照不与看板计数共享数据
+	s.Histogram[0] = 99
+	s.Prizes["一等奖"] = 99
+	if d := dashboardSnapshot(); d.Histogram[0] != 1 || d.Prizes["一等奖"] != 1 {
+		t.Fatalf("snapshot aliases dashboard state: %+v", d)
+	}
+}
@@ -0,0 +1,182 @@
+//go:build headless
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// 无界面模式（go build -tags headless），用于在没有图形界面的 Linux 主机或容器中运行答题服务。
+// 题库、兑换码、结果文件和监听地址可以写在活动配置文件中，命令行参数优先。
+func main() {
+	configFile := flag.String("config", "", "活动配置文件，默认为数据目录下的 event.json")
+	dir := flag.String("data", "", "数据目录，默认使用活动配置中的 data_dir，都未设置时为当前目录")
+	bank := flag.String("questions", "", "题库 Excel 文件")
+	codes := flag.String("codes", "", "兑换码 Excel 文件")
+	results := flag.String("results", "", "结果保存文件，扩展名为 .jsonl 时按 JSON Lines 保存，其它为 Excel")
+	addr := flag.String("addr", "", "监听地址，如 :8080")
+	host := flag.String("host", "", "二维码中使用的 IP 或主机名，默认自动检测")
+	mdns := flag.String("mdns", "", "通过 mDNS 广播的主机名，如 quiz-branch01（访问 quiz-branch01.local）")
+	useTLS := flag.Bool("tls", false, "以 HTTPS 提供服务，证书保存在数据目录的 tls 目录")
+	captive := flag.Bool("captive", false, "热点模式：在 53 端口提供 DNS（所有域名都解析到本机），80 端口的请求重定向到答题页面")
+	verifyAudit := flag.Bool("verify-audit", false, "校验数据目录 logs 下审计日志的 Hash 链后退出")
+	writeConfig := flag.String("write-config", "", "将合并命令行参数后的活动配置写入该文件（.toml 或 .json）后退出")
+	flag.Parse()
+	flagBank, flagCodes := *bank, *codes
+
+	eventConfigFile = *configFile
+
+	// 命令行指定的数据目录优先于配置文件中的 data_dir
+	cfg, err := restoreEventConfig(*dir)
+	if err != nil {
+		log.Fatalf("读取活动配置错误: %v", err)
+	}
+	mdnsHost := strings.TrimSuffix(strings.TrimSpace(*mdns), ".local")
+	if err := validateMDNSName(mdnsHost); err != nil {
+		log.Fatalf("mDNS 主机名错误: %v", err)
+	}
+	if err := validateAdvertiseHost(strings.TrimSpace(*host)); err != nil {
+		log.Fatalf("访问地址错误: %v", err)
+	}
+
+	if *bank == "" {
+		*bank = cfg.QuestionBank
+	}
+	keepRules := len(cfg.PrizeLevels) > 0
+	if *codes == "" {
+		*codes = cfg.CodesFile
+	} else {
+		// 命令行指定的兑换码文件以其中的奖品等级为准
+		keepRules = false
+	}
+
+	// 命令行参数只在本次运行中生效，此处不保存，以免 -verify-audit、-write-config 产生副作用
+	mutex.Lock()
+	if *results != "" {
+		setResultPathLocked(*results)
+	}
+	if *addr != "" {
+		listenAddr = *addr
+	}
+	if *useTLS {
+		tlsEnabled = true
+	}
+	if *captive {
+		captivePortal = true
+	}
+	if mdnsHost != "" {
+		mdnsName = mdnsHost
+	}
+	if *host != "" {
+		advertiseHost = strings.TrimSpace(*host)
+	}
+	// 运行中保存配置时，命令行覆盖的项保持配置文件中的值
+	keepConfigFileValues = func(c *EventConfig) {
+		if *results != "" {
+			c.Storage = cfg.Storage
+		}
+		if *addr != "" {
+			c.ListenAddr = cfg.ListenAddr
+		}
+		if *useTLS {
+			c.TLS = cfg.TLS
+		}
+		if *captive {
+			c.CaptivePortal = cfg.CaptivePortal
+		}
+		if mdnsHost != "" {
+			c.MDNSName = cfg.MDNSName
+		}
+		if *host != "" {
+			c.AdvertiseHost = cfg.AdvertiseHost
+		}
+		if flagBank != "" {
+			c.QuestionBank = cfg.QuestionBank
+		}
+		if flagCodes != "" {
+			c.CodesFile = cfg.CodesFile
+		}
+	}
+	mutex.Unlock()
+
+	if *verifyAudit {
+		n, err := verifyAuditLog()
+		if err != nil {
+			log.Fatalf("审计日志校验失败（已通过 %d 条）: %v", n, err)
+		}
+		log.Printf("审计日志校验通过，共 %d 条记录", n)
+		return
+	}
+
+	if *writeConfig != "" {
+		mutex.Lock()
+		out := currentEventConfigLocked()
+		mutex.Unlock()
+		out.DataDir = dataDir
+		out.QuestionBank = *bank
+		out.CodesFile = *codes
+		if err := SaveEventConfig(*writeConfig, out); err != nil {
+			log.Fatalf("写入活动配置错误: %v", err)
+		}
+		log.Printf("活动配置已写入 %s", *writeConfig)
+		return
+	}
+
+	if *configFile != "" {
+		log.Printf("活动配置文件 %s 只读取不修改，运行中的修改保存在 %s", *configFile, eventStatePath())
+	}
+
+	if *bank == "" {
+		log.Fatal("未指定题库，请使用 -questions 或在活动配置中设置 question_bank")
+	}
+	n, err := loadQuestionBank(*bank)
+	if err != nil {
+		log.Fatalf("加载题库错误: %v", err)
+	}
+	log.Printf("已加载题库: %d 道题目", n)
+
+	if *codes != "" {
+		levels, available, err := loadPrizeCodes(*codes, keepRules)
+		if err != nil {
+			log.Fatalf("加载兑换码错误: %v", err)
+		}
+		log.Printf("已加载 %d 个奖品等级, %d 个可用兑换码", levels, available)
+	}
+
+	stopped := make(chan struct{})
+	u, err := startServer(func() { close(stopped) })
+	if err != nil {
+		log.Fatalf("启动服务错误: %v", err)
+	}
+	log.Printf("服务运行中，访问: %s/identity.html，结果文件: %s", u, resultPath())
+	if key, err := loadAdminPassword(); err != nil {
+		log.Printf("读取管理密码错误: %v", err)
+	} else {
+		if strings.HasPrefix(u, "https://") {
+			log.Printf("HTTPS 根证书下载: %s/ca.crt，安装并信任后浏览器不再提示证书不安全", u)
+		}
+		log.Printf("网页管理后台: %s/admin，初始账号 %s，初始密码 %s（修改后以新密码为准）", u, adminUser, key)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sig:
+		log.Println("正在停止服务，再次按 Ctrl+C 立即退出")
+		go stopServer(drainPeriod)
+		select {
+		case <-stopped:
+		case <-sig:
+			// 取得 mutex 后退出，不会中断正在写入的结果
+			mutex.Lock()
+			log.Println("已强制退出")
+			os.Exit(1)
+		}
+	case <-stopped:
+	}
+}
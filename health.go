@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ReadyProblem 服务未就绪的一个原因，Check 为检查项，Reason 为便于程序判断的原因代码
+type ReadyProblem struct {
+	Check   string `json:"check"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// storeWritable 结果文件能否写入：文件已存在时尝试以追加方式打开，不存在时在所在目录试建临时文件
+func storeWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return f.Close()
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	_ = tmp.Close()
+	return os.Remove(name)
+}
+
+// readyProblems 检查题库、各等级的兑换码、结果存储和答题开放状态，全部正常时返回空列表
+func readyProblems() []ReadyProblem {
+	problems := []ReadyProblem{}
+	mutex.Lock()
+	if len(questions) == 0 {
+		problems = append(problems, ReadyProblem{Check: "questions", Reason: "no_questions", Message: "未加载题库"})
+	}
+	if len(prizeLevels) == 0 {
+		problems = append(problems, ReadyProblem{Check: "prize_codes", Reason: "no_prize_codes", Message: "未加载兑换码"})
+	}
+	// 兑换码类型的等级没有未使用的兑换码时，答对的人拿不到该等级的奖品
+	unused := map[string]int{}
+	for _, c := range prizeCodes {
+		if !c.Used {
+			unused[c.Level]++
+		}
+	}
+	for _, l := range prizeLevels {
+		if l.Kind == PrizeKindCode && unused[l.Level] == 0 {
+			problems = append(problems, ReadyProblem{Check: "prize_codes", Reason: "codes_exhausted", Message: "奖品等级 " + l.Level + " 没有可用的兑换码"})
+		}
+	}
+	st := attemptStatusLocked()
+	path := resultFileLocked()
+	mutex.Unlock()
+
+	if err := storeWritable(path); err != nil {
+		problems = append(problems, ReadyProblem{Check: "result_store", Reason: "not_writable", Message: "结果文件无法写入: " + err.Error()})
+	}
+	if !st.Open {
+		problems = append(problems, ReadyProblem{Check: "event", Reason: st.State, Message: st.Message})
+	}
+	return problems
+}
+
+// setupHealthHandlers 供看门狗和展台启动程序使用的存活与就绪检查：
+// /healthz 进程正常即返回 200；/readyz 可以答题时返回 200，否则返回 503 和原因列表
+func setupHealthHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		problems := readyProblems()
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if len(problems) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"ready":    len(problems) == 0,
+			"problems": problems,
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadyProblems(t *testing.T) {
+	mutex.Lock()
+	savedQ, savedLevels, savedCodes := questions, prizeLevels, prizeCodes
+	savedDir, savedFile, savedSchedule, savedAccepting := dataDir, resultsFile, schedule, acceptingSubmissions
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		questions, prizeLevels, prizeCodes = savedQ, savedLevels, savedCodes
+		dataDir, resultsFile, schedule, acceptingSubmissions = savedDir, savedFile, savedSchedule, savedAccepting
+		mutex.Unlock()
+	})
+
+	levels := []PrizeLevel{
+		{Level: "一等奖", Score: 100, Kind: PrizeKindCode},
+		{Level: "二等奖", Score: 80, Kind: PrizeKindCode},
+		{Level: "纪念品", Score: 60, Kind: PrizeKindItem, Stock: 5},
+	}
+	codes := []PrizeCode{{Code: "A1", Level: "一等奖"}, {Code: "B1", Level: "二等奖"}}
+	// 上级路径是普通文件，无法在其中创建结果文件
+	underFile := filepath.Join(t.TempDir(), "file", "dir")
+	if err := os.WriteFile(filepath.Dir(underFile), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		edit    func()
+		reasons []string
+	}{
+		{"ready", func() {}, nil},
+		{"no questions", func() { questions = nil }, []string{"no_questions"}},
+		{"no prize codes", func() { prizeLevels, prizeCodes = nil, nil }, []string{"no_prize_codes"}},
+		{"one level used up", func() {
+			prizeCodes = []PrizeCode{{Code: "A1", Level: "一等奖"}, {Code: "B1", Level: "二等奖", Used: true}}
+		}, []string{"codes_exhausted"}},
+		{"one level without codes", func() { prizeCodes = prizeCodes[:1] }, []string{"codes_exhausted"}},
+		{"result file not writable", func() { resultsFile = filepath.Join(underFile, "records.xlsx") }, []string{"not_writable"}},
+		{"paused", func() { acceptingSubmissions = false }, []string{QuizPaused}},
+		{"event ended", func() { schedule = ScheduleConfig{EndDate: "2000-01-01"} }, []string{QuizEnded}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex.Lock()
+			questions = []Question{{ID: "1", Type: "single", Answer: []int{0}}}
+			prizeLevels = levels
+			prizeCodes = append([]PrizeCode(nil), codes...)
+			dataDir, resultsFile, schedule, acceptingSubmissions = t.TempDir(), "", ScheduleConfig{}, true
+			tt.edit()
+			mutex.Unlock()
+
+			problems := readyProblems()
+			if len(problems) != len(tt.reasons) {
+				t.Fatalf("readyProblems() = %+v, want reasons %v", problems, tt.reasons)
+			}
+			for i, p := range problems {
+				if p.Reason != tt.reasons[i] || p.Message == "" {
+					t.Fatalf("readyProblems()[%d] = %+v, want reason %s", i, p, tt.reasons[i])
+				}
+			}
+
+			mux := http.NewServeMux()
+			setupHealthHandlers(mux)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			var body struct {
+				Ready    bool           `json:"ready"`
+				Problems []ReadyProblem `json:"problems"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			wantCode := http.StatusOK
+			if len(tt.reasons) > 0 {
+				wantCode = http.StatusServiceUnavailable
+			}
+			if w.Code != wantCode || body.Ready != (len(tt.reasons) == 0) || len(body.Problems) != len(tt.reasons) {
+				t.Fatalf("/readyz = %d %+v, want %d", w.Code, body, wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// LevelStock 某个奖品等级的库存情况
+type LevelStock struct {
+	Level     string `json:"level"`
+	Remaining int    `json:"remaining"`
+	Total     int    `json:"total"`
+	Threshold int    `json:"threshold"`
+}
+
+// InventoryAlert 库存预警事件（低于阈值或已发完）
+type InventoryAlert struct {
+	Level     string
+	Remaining int
+	Exhausted bool
+}
+
+var (
+	// defaultLowStock 奖品等级未单独配置预警阈值时使用的默认值
+	defaultLowStock = 5
+	// lowStockAlerted 记录已经触发过低库存预警的等级，避免每次发放都重复提醒
+	lowStockAlerted = map[string]bool{}
+
+	// onInventoryChange 库存变化时回调（由管理界面设置）。
+	// 回调在持有 mutex 时执行，不能在回调中同步加锁。
+	onInventoryChange func()
+	// onInventoryAlert 库存低于阈值或发完时回调（由管理界面设置），同样不能同步加锁
+	onInventoryAlert func(InventoryAlert)
+)
+
+// levelThreshold 返回某等级的预警阈值，调用方需持有 mutex
+func levelThreshold(level string) int {
+	for _, l := range prizeLevels {
+		if l.Level == level && l.LowStock > 0 {
+			return l.LowStock
+		}
+	}
+	return defaultLowStock
+}
+
+// inventorySnapshotLocked 统计每个奖品等级的剩余数量（兑换码或实物库存），调用方需持有 mutex。
+// 不限量的参与奖不统计。
+func inventorySnapshotLocked() []LevelStock {
+	out := []LevelStock{}
+	index := map[string]int{}
+	for _, l := range prizeLevels {
+		if !l.limitedStock() {
+			continue
+		}
+		st := LevelStock{Level: l.Level, Threshold: levelThreshold(l.Level)}
+		if l.Kind != PrizeKindCode {
+			st.Total = l.Stock
+			st.Remaining = itemRemainingLocked(l)
+		}
+		index[l.Level] = len(out)
+		out = append(out, st)
+	}
+	for _, c := range prizeCodes {
+		i, ok := index[c.Level]
+		if !ok {
+			// 兑换码的等级没有在 Sheet1 中配置，同样统计出来便于发现问题
+			index[c.Level] = len(out)
+			out = append(out, LevelStock{Level: c.Level, Threshold: defaultLowStock})
+			i = index[c.Level]
+		}
+		out[i].Total++
+		if !c.Used {
+			out[i].Remaining++
+		}
+	}
+	return out
+}
+
+// inventorySnapshot 统计每个奖品等级的剩余数量（兑换码或实物库存）
+func inventorySnapshot() []LevelStock {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return inventorySnapshotLocked()
+}
+
+// resetInventoryAlerts 重新加载兑换码后清空预警状态，调用方需持有 mutex
+func resetInventoryAlerts() {
+	lowStockAlerted = map[string]bool{}
+}
+
+// checkInventoryLocked 在发放某等级奖品后检查库存，调用方需持有 mutex。
+// 低于阈值时只提醒一次，发完时写日志并再次提醒。
+func checkInventoryLocked(level string) {
+	limited := false
+	remaining := 0
+	for _, st := range inventorySnapshotLocked() {
+		if st.Level == level {
+			limited = true
+			remaining = st.Remaining
+		}
+	}
+
+	var alert *InventoryAlert
+	switch {
+	case !limited:
+		// 不限量的参与奖无需预警
+	case remaining == 0:
+		log.Printf("奖品等级 %s 的奖品已全部发完", level)
+		alert = &InventoryAlert{Level: level, Remaining: 0, Exhausted: true}
+	case remaining <= levelThreshold(level) && !lowStockAlerted[level]:
+		lowStockAlerted[level] = true
+		log.Printf("奖品等级 %s 库存不足，剩余 %d 个", level, remaining)
+		alert = &InventoryAlert{Level: level, Remaining: remaining}
+	}
+
+	if alert != nil && onInventoryAlert != nil {
+		onInventoryAlert(*alert)
+	}
+	if onInventoryChange != nil {
+		onInventoryChange()
+	}
+}
+
+// formatInventory 生成用于界面显示的库存文本
+func formatInventory(stocks []LevelStock) string {
+	if len(stocks) == 0 {
+		return "奖品库存: 无"
+	}
+	s := ""
+	for i, st := range stocks {
+		if i > 0 {
+			s += "\n"
+		}
+		mark := ""
+		if st.Remaining == 0 {
+			mark = " [已发完]"
+		} else if st.Remaining <= st.Threshold {
+			mark = " [库存不足]"
+		}
+		s += fmt.Sprintf("%s: %d / %d%s", st.Level, st.Remaining, st.Total, mark)
+	}
+	return s
+}
+
+// alertMessage 预警提示文本
+func (a InventoryAlert) alertMessage() string {
+	if a.Exhausted {
+		return fmt.Sprintf("奖品等级 %s 的奖品已全部发完", a.Level)
+	}
+	return fmt.Sprintf("奖品等级 %s 库存不足，仅剩 %d 个", a.Level, a.Remaining)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckInventoryAlerts(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	savedLevels, savedCodes, savedAlerted := prizeLevels, prizeCodes, lowStockAlerted
+	savedAlert, savedChange := onInventoryAlert, onInventoryChange
+	defer func() {
+		prizeLevels, prizeCodes, lowStockAlerted = savedLevels, savedCodes, savedAlerted
+		onInventoryAlert, onInventoryChange = savedAlert, savedChange
+	}()
+
+	prizeLevels = []PrizeLevel{
+		{Level: "一等奖", Score: 100, Kind: PrizeKindCode, LowStock: 2},
+		{Level: "参与奖", Kind: PrizeKindConsolation},
+	}
+	prizeCodes = []PrizeCode{
+		{Code: "A1", Level: "一等奖"}, {Code: "A2", Level: "一等奖"},
+		{Code: "A3", Level: "一等奖"}, {Code: "A4", Level: "一等奖"},
+	}
+	resetInventoryAlerts()
+	var alerts []InventoryAlert
+	changes := 0
+	onInventoryAlert = func(a InventoryAlert) { alerts = append(alerts, a) }
+	onInventoryChange = func() { changes++ }
+
+	// 依次发放兑换码：剩 3 个时不提醒，剩 2 个时提醒一次，剩 1 个时不再重复提醒，发完时再提醒
+	for i := range prizeCodes {
+		prizeCodes[i].Used = true
+		checkInventoryLocked("一等奖")
+	}
+	checkInventoryLocked("参与奖")
+	want := []InventoryAlert{{Level: "一等奖", Remaining: 2}, {Level: "一等奖", Exhausted: true}}
+	if !reflect.DeepEqual(alerts, want) {
+		t.Fatalf("alerts = %+v, want %+v", alerts, want)
+	}
+	if changes != 5 {
+		t.Fatalf("inventory change callbacks = %d, want 5", changes)
+	}
+
+	stocks := inventorySnapshotLocked()
+	if len(stocks) != 1 || stocks[0] != (LevelStock{Level: "一等奖", Remaining: 0, Total: 4, Threshold: 2}) {
+		t.Fatalf("inventorySnapshotLocked() = %+v", stocks)
+	}
+}
+
+func TestFormatInventory(t *testing.T) {
+	tests := []struct {
+		stocks []LevelStock
+		want   string
+	}{
+		{nil, "奖品库存: 无"},
+		{[]LevelStock{
+			{Level: "一等奖", Remaining: 10, Total: 10, Threshold: 5},
+			{Level: "二等奖", Remaining: 3, Total: 20, Threshold: 5},
+			{Level: "三等奖", Remaining: 0, Total: 5, Threshold: 5},
+		}, "一等奖: 10 / 10\n二等奖: 3 / 20 [库存不足]\n三等奖: 0 / 5 [已发完]"},
+	}
+	for _, tt := range tests {
+		if got := formatInventory(tt.stocks); got != tt.want {
+			t.Errorf("formatInventory() = %q, want %q", got, tt.want)
+		}
+	}
+}
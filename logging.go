@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultLogMaxSizeMB 日志文件默认达到多大时轮换（MB）
+	defaultLogMaxSizeMB = 10
+	// defaultAccessLogKeep 默认保留的旧访问日志个数
+	defaultAccessLogKeep = 5
+)
+
+// LogConfig 访问日志和审计日志设置，留空使用默认值
+type LogConfig struct {
+	MaxSizeMB     int  `json:"max_size_mb,omitempty" toml:"max_size_mb,omitempty"`       // 日志文件达到多大时轮换（MB）
+	AccessKeep    int  `json:"access_keep,omitempty" toml:"access_keep,omitempty"`       // 保留的旧访问日志个数（审计日志全部保留）
+	DisableAccess bool `json:"disable_access,omitempty" toml:"disable_access,omitempty"` // 不记录访问日志
+}
+
+// logConfig 日志设置，应用活动配置时整体替换。每个请求都要读取，不使用 mutex，
+// 以免排在写入结果文件的提交请求后面
+var logConfig atomic.Pointer[LogConfig]
+
+// currentLogConfig 当前的日志设置
+func currentLogConfig() LogConfig {
+	if c := logConfig.Load(); c != nil {
+		return *c
+	}
+	return LogConfig{}
+}
+
+// validateLogConfig 检查日志设置
+func validateLogConfig(c LogConfig) error {
+	if c.MaxSizeMB < 0 || c.AccessKeep < 0 {
+		return fmt.Errorf("日志设置不能为负数")
+	}
+	return nil
+}
+
+// logsDir 日志保存目录，调用方需持有 mutex
+func logsDir() string {
+	return filepath.Join(dataDir, "logs")
+}
+
+// rotatingLog 按行追加 JSON 的日志文件，超过大小后改名为 name-时间-序号.jsonl 并新建文件
+type rotatingLog struct {
+	name string // 文件名（不含扩展名），如 access
+
+	mu   sync.Mutex
+	f    *os.File
+	path string
+	size int64
+}
+
+// currentPath 日志目录 dir 中当前日志文件的位置
+func (l *rotatingLog) currentPath(dir string) string {
+	return filepath.Join(dir, l.name+".jsonl")
+}
+
+// rotatedFiles 日志目录 dir 中已轮换的旧日志文件，按时间从早到晚排列
+func (l *rotatingLog) rotatedFiles(dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, l.name+"-*.jsonl"))
+	sort.Strings(files)
+	return files
+}
+
+// openLocked 打开（必要时新建）日志目录 dir 中的当前日志文件，目录变化后重新打开，调用方需持有 l.mu
+func (l *rotatingLog) openLocked(dir string) error {
+	path := l.currentPath(dir)
+	if l.f != nil && l.path == path {
+		return nil
+	}
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.f, l.path, l.size = f, path, st.Size()
+	return nil
+}
+
+// rotateLocked 将当前文件改名为带时间的旧文件，并删除超出 keep 个的旧文件（keep 为 0 时全部保留），调用方需持有 l.mu
+func (l *rotatingLog) rotateLocked(keep int) error {
+	_ = l.f.Close()
+	l.f = nil
+	// 文件名按时间和序号排序即为轮换顺序，如 audit-20261018-150405-000.jsonl
+	stamp := time.Now().Format("20060102-150405")
+	var dst string
+	for i := 0; ; i++ {
+		dst = filepath.Join(filepath.Dir(l.path), fmt.Sprintf("%s-%s-%03d.jsonl", l.name, stamp, i))
+		if _, err := os.Stat(dst); os.IsNotExist(err) {
+			break
+		}
+	}
+	if err := os.Rename(l.path, dst); err != nil {
+		return err
+	}
+	dir := filepath.Dir(l.path)
+	if old := l.rotatedFiles(dir); keep > 0 && len(old) > keep {
+		for _, p := range old[:len(old)-keep] {
+			_ = os.Remove(p)
+		}
+	}
+	return l.openLocked(dir)
+}
+
+// writeLine 在日志目录 dir 中追加一行，文件超过 maxBytes 时先轮换，旧文件保留 keep 个
+func (l *rotatingLog) writeLine(dir string, line []byte, maxBytes int64, keep int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.openLocked(dir); err != nil {
+		return err
+	}
+	if l.size > 0 && l.size+int64(len(line))+1 > maxBytes {
+		if err := l.rotateLocked(keep); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(append(line, '\n'))
+	l.size += int64(n)
+	return err
+}
+
+// close 关闭日志文件
+func (l *rotatingLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+}
+
+// logLimits 日志轮换大小和保留的旧访问日志个数
+func logLimits() (int64, int) {
+	c := currentLogConfig()
+	mb, keep := c.MaxSizeMB, c.AccessKeep
+	if mb <= 0 {
+		mb = defaultLogMaxSizeMB
+	}
+	if keep <= 0 {
+		keep = defaultAccessLogKeep
+	}
+	return int64(mb) << 20, keep
+}
+
+var (
+	// accessLog 访问日志 logs/access.jsonl
+	accessLog = &rotatingLog{name: "access"}
+
+	logErrorsMu sync.Mutex
+	// logErrors 各类日志上次报告写入错误的时间，避免磁盘满等情况下刷屏
+	logErrors = map[string]time.Time{}
+)
+
+// logOnce 同一类错误每分钟最多输出一次
+func logOnce(kind, format string, args ...interface{}) {
+	logErrorsMu.Lock()
+	defer logErrorsMu.Unlock()
+	if time.Since(logErrors[kind]) < time.Minute {
+		return
+	}
+	logErrors[kind] = time.Now()
+	log.Printf(format, args...)
+}
+
+// AccessEntry 访问日志中的一行
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"`
+	IP        string    `json:"ip"`
+	AttemptID string    `json:"attempt_id,omitempty"`
+}
+
+// accessEntryKey 请求 context 中 *AccessEntry 的键，处理函数可以补充答题编号
+type accessEntryKey struct{}
+
+// setAccessAttempt 在访问日志中记录本次请求对应的答题编号
+func setAccessAttempt(r *http.Request, id string) {
+	if e, ok := r.Context().Value(accessEntryKey{}).(*AccessEntry); ok {
+		e.AttemptID = id
+	}
+}
+
+// statusRecorder 记录响应状态码和大小，同时保留 Flush 以支持看板推送
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// accessLogHandler 每个请求结束后在日志目录 dir 的访问日志中写一行（方法、路径、状态码、耗时、来源 IP、答题编号）
+func accessLogHandler(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentLogConfig().DisableAccess {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		e := &AccessEntry{Time: start, Method: r.Method, Path: r.URL.Path, IP: clientIP(r)}
+		if id := r.URL.Query().Get("id"); id != "" && strings.HasPrefix(r.URL.Path, "/api/") {
+			e.AttemptID = id
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, e)))
+		e.Status = rec.status
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		e.Bytes = rec.bytes
+		e.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		line, err := json.Marshal(e)
+		if err == nil {
+			maxBytes, keep := logLimits()
+			err = accessLog.writeLine(dir, line, maxBytes, keep)
+		}
+		if err != nil {
+			logOnce("access", "写入访问日志错误: %v", err)
+		}
+	})
+}
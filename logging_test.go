@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useLogConfig 使用给定的日志设置，测试结束后恢复
+func useLogConfig(t *testing.T, c LogConfig) {
+	t.Helper()
+	saved := logConfig.Load()
+	logConfig.Store(&c)
+	t.Cleanup(func() {
+		logConfig.Store(saved)
+		accessLog.close()
+	})
+}
+
+// readAccessLog 读取访问日志中的全部记录
+func readAccessLog(t *testing.T, dir string) []AccessEntry {
+	t.Helper()
+	accessLog.close()
+	f, err := os.Open(filepath.Join(dir, "access.jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []AccessEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e AccessEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/submit" {
+			setAccessAttempt(r, "a1")
+			writeError(w, "答题已过期或无效，请重新开始答题", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}
+	tests := []struct {
+		name    string
+		cfg     LogConfig
+		path    string
+		want    int // 写入的记录数
+		status  int
+		attempt string
+	}{
+		{"page", LogConfig{}, "/identity.html", 1, http.StatusOK, ""},
+		{"attempt from handler", LogConfig{}, "/api/submit", 1, http.StatusForbidden, "a1"},
+		{"attempt from query", LogConfig{}, "/api/certificate?id=b2", 1, http.StatusOK, "b2"},
+		{"disabled", LogConfig{DisableAccess: true}, "/identity.html", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useLogConfig(t, tt.cfg)
+			dir := t.TempDir()
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			r.RemoteAddr = "10.0.0.7:4000"
+			accessLogHandler(dir, http.HandlerFunc(h)).ServeHTTP(httptest.NewRecorder(), r)
+
+			entries := readAccessLog(t, dir)
+			if len(entries) != tt.want {
+				t.Fatalf("access log entries = %d, want %d", len(entries), tt.want)
+			}
+			if tt.want == 0 {
+				return
+			}
+			e := entries[0]
+			if e.Status != tt.status || e.AttemptID != tt.attempt || e.IP != "10.0.0.7" || e.Method != http.MethodPost || e.Bytes == 0 {
+				t.Fatalf("access log entry = %+v", e)
+			}
+		})
+	}
+}
+
+func TestRotatingLog(t *testing.T) {
+	dir := t.TempDir()
+	l := &rotatingLog{name: "test"}
+	defer l.close()
+	line := []byte(`{"n":"0123456789"}`)
+	for i := 0; i < 6; i++ {
+		// 每个文件只能放下两行
+		if err := l.writeLine(dir, line, int64(2*(len(line)+1)), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(l.rotatedFiles(dir)); got != 2 {
+		t.Fatalf("rotated files = %d, want 2 kept", got)
+	}
+	b, err := os.ReadFile(l.currentPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2 * (len(line) + 1); len(b) != want {
+		t.Fatalf("current file size = %d, want %d", len(b), want)
+	}
+
+	// 目录变化后写入新目录
+	other := t.TempDir()
+	if err := l.writeLine(other, line, 1<<20, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(l.currentPath(other)); err != nil {
+		t.Fatal(err)
+	}
+}
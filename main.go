@@ -1,22 +1,18 @@
+//go:build !headless
+
 package main
 
 import (
-	"embed"
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"html/template"
 	"image/png"
 	"io"
 	"log"
 	"net"
-	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
-
-	"bytes"
-	"os"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -26,96 +22,152 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"github.com/xuri/excelize/v2"
-
-	qrcode "github.com/skip2/go-qrcode"
 )
 
-//go:embed web/*
-var webFS embed.FS
+// squareLayout 强制子元素为一个正方形（边长 = min(可用宽, 可用高)），并居中。
+// 实现 fyne.Layout 接口。
+type squareLayout struct{}
 
-type Question struct {
-	ID      string   `json:"id"`
-	Type    string   `json:"type"`
-	Prompt  string   `json:"question"`
-	Options []string `json:"options"`
-	Answer  []int    `json:"answer"`
-	Score   int      `json:"score"`
-}
+func main() {
+	os.Setenv("FYNE_SCALE", "1")
 
-// CheckUserAnswered 检查用户今天是否已经答题
-func CheckUserAnswered(path, phoneHash, idHash string) (bool, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return false, nil
-	}
+	a := app.NewWithID("com.example.quizmanager")
+	a.Settings().SetTheme(theme.DarkTheme())
 
-	f, err := excelize.OpenFile(path)
+	// 读取上次保存的奖品规则等活动配置（窗口标题也来自配置）
+	cfg, err := restoreEventConfig("")
 	if err != nil {
-		return false, err
+		log.Printf("读取活动配置错误: %v", err)
 	}
 
-	rows, err := f.GetRows("Sheet1")
-	if err != nil {
-		return false, err
-	}
+	w := a.NewWindow(currentBranding().AdminTitle)
+	w.Resize(fyne.NewSize(1000, 640))
 
-	today := time.Now().Format("2006-01-02")
+	// Left controls
+	status := widget.NewLabel("就绪")
+	qCount := widget.NewLabel("题目: 0")
+	codeCount := widget.NewLabel("兑换码: 0")
+	stockLabel := widget.NewLabel(formatInventory(inventorySnapshot()))
 
-	for i, r := range rows {
-		if i == 0 {
-			continue // skip header
+	// 自动加载上次使用的题库和兑换码
+	if cfg.QuestionBank != "" {
+		if n, err := loadQuestionBank(cfg.QuestionBank); err != nil {
+			log.Printf("加载题库错误: %v", err)
+		} else {
+			qCount.SetText(fmt.Sprintf("题目: %d", n))
 		}
-		if len(r) >= 8 { // 确保有足够的列，第9列是user_hash
-			timestamp := strings.TrimSpace(r[0])
-			recordPhoneHash := strings.TrimSpace(r[2]) // 第9列是id_hash
-			recordIdHash := strings.TrimSpace(r[3])    // 第10列是phone_hash
-
-			// 检查是否是今天的记录并且哈希匹配
-			if strings.Contains(timestamp, today) && recordPhoneHash == phoneHash || strings.Contains(timestamp, today) && recordIdHash == idHash {
-				return true, nil
-			}
+	}
+	if cfg.CodesFile != "" {
+		if _, available, err := loadPrizeCodes(cfg.CodesFile, len(cfg.PrizeLevels) > 0); err != nil {
+			log.Printf("加载兑换码错误: %v", err)
+		} else {
+			codeCount.SetText(fmt.Sprintf("可用兑换码: %d", available))
+			stockLabel.SetText(formatInventory(inventorySnapshot()))
 		}
 	}
 
-	return false, nil
-}
+	// 监听端口，下次启动服务时生效
+	mutex.Lock()
+	_, port, _ := net.SplitHostPort(listenAddr)
+	fallback := listenFallback
+	mutex.Unlock()
+	portEntry := widget.NewEntry()
+	portEntry.SetText(port)
+	portEntry.OnChanged = func(text string) {
+		v, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || v <= 0 || v > 65535 {
+			return
+		}
+		if err := setListenPort(v); err != nil {
+			log.Printf("保存活动配置错误: %v", err)
+		}
+	}
+	chkFallback := widget.NewCheck("端口被占用时自动换用其它端口", nil)
+	chkFallback.SetChecked(fallback)
+	chkFallback.OnChanged = func(on bool) {
+		if err := setListenFallback(on); err != nil {
+			log.Printf("保存活动配置错误: %v", err)
+		}
+	}
 
-// squareLayout 强制子元素为一个正方形（边长 = min(可用宽, 可用高)），并居中。
-// 实现 fyne.Layout 接口。
-type squareLayout struct{}
+	// HTTPS：证书由本机生成的根证书签发，下次启动服务时生效
+	mutex.Lock()
+	useTLS := tlsEnabled
+	mutex.Unlock()
+	chkTLS := widget.NewCheck("启用 HTTPS（下次启动服务时生效）", nil)
+	chkTLS.SetChecked(useTLS)
+	chkTLS.OnChanged = func(on bool) {
+		if err := setTLSEnabled(on); err != nil {
+			log.Printf("保存活动配置错误: %v", err)
+		}
+	}
 
-var (
-	mutex         sync.Mutex
-	questions     []Question
-	prizeLevels   []PrizeLevel
-	prizeCodes    []PrizeCode
-	usedCodes     []string
-	resultsXlsx   string
-	server        *http.Server
-	serverRunning bool
-	listenAddr    = ":8080"
-	baseURL       = ""
-	dataDir       = "."
-)
+	// 热点模式：没有场地 Wi-Fi 时由本机开热点，手机加入后自动弹出答题页面
+	mutex.Lock()
+	captive := captivePortal
+	mutex.Unlock()
+	chkCaptive := widget.NewCheck("热点模式：手机加入热点后自动弹出答题页（下次启动服务时生效）", nil)
+	chkCaptive.SetChecked(captive)
+	chkCaptive.OnChanged = func(on bool) {
+		if err := setCaptivePortal(on); err != nil {
+			log.Printf("保存活动配置错误: %v", err)
+		}
+	}
 
-func main() {
-	os.Setenv("FYNE_SCALE", "1")
+	// 低库存预警阈值（未在兑换码 Excel 中单独配置的等级使用该值）
+	lowStockEntry := widget.NewEntry()
+	lowStockEntry.SetText(strconv.Itoa(defaultLowStock))
+	lowStockEntry.OnChanged = func(text string) {
+		v, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || v < 0 {
+			return
+		}
+		mutex.Lock()
+		defaultLowStock = v
+		resetInventoryAlerts()
+		stocks := inventorySnapshotLocked()
+		mutex.Unlock()
+		stockLabel.SetText(formatInventory(stocks))
+		if err := saveCurrentEventConfig(); err != nil {
+			log.Printf("保存活动配置错误: %v", err)
+		}
+	}
 
-	a := app.NewWithID("com.example.quizmanager")
-	a.Settings().SetTheme(theme.DarkTheme())
-	w := a.NewWindow("反诈答题 管理后台 by-杨典")
-	w.Resize(fyne.NewSize(1000, 640))
+	// 发放兑换码后在界面上实时刷新剩余数量，回调来自 HTTP 协程，需切回主线程
+	onInventoryChange = func() {
+		fyne.Do(func() {
+			stocks := inventorySnapshot()
+			remaining := 0
+			for _, st := range stocks {
+				remaining += st.Remaining
+			}
+			codeCount.SetText(fmt.Sprintf("可用兑换码: %d", remaining))
+			stockLabel.SetText(formatInventory(stocks))
+		})
+	}
+	onInventoryAlert = func(alert InventoryAlert) {
+		msg := alert.alertMessage()
+		fyne.Do(func() {
+			status.SetText("⚠ " + msg)
+			a.SendNotification(fyne.NewNotification("奖品库存预警", msg))
+		})
+	}
 
-	// Left controls
-	status := widget.NewLabel("就绪")
-	qCount := widget.NewLabel("题目: 0")
-	codeCount := widget.NewLabel("兑换码: 0")
+	// 网页管理后台更新题库后同步刷新题目数量
+	onQuestionsChange = func(n int) {
+		fyne.Do(func() {
+			qCount.SetText(fmt.Sprintf("题目: %d", n))
+		})
+	}
 
 	// QR image (square) - canvas Image
 	qrImg1 := canvas.NewImageFromImage(nil)
 	qrImg1.FillMode = canvas.ImageFillContain
 	// ensure min size square
 	qrImg1.SetMinSize(fyne.NewSize(200, 200))
+	// 二维码下方的访问链接，生成二维码时显示实际监听的地址和协议，停止服务后恢复提示
+	const linkPlaceholder = "启动 Web 服务后显示访问链接"
+	rightLinkLabel := widget.NewLabelWithStyle(linkPlaceholder, fyne.TextAlignLeading, fyne.TextStyle{})
 
 	// Buttons
 	btnLoadQ := widget.NewButton("加载题库 Excel", func() {
@@ -135,15 +187,13 @@ func main() {
 				dialog.ShowError(err, w)
 				return
 			}
-			qs, err := LoadQuestionsFromExcel(tmp)
+			n, err := loadQuestionBank(tmp)
 			if err != nil {
 				dialog.ShowError(err, w)
 				return
 			}
-			mutex.Lock()
-			questions = qs
-			mutex.Unlock()
-			qCount.SetText(fmt.Sprintf("题目: %d", len(qs)))
+			audit(AuditQuestions, auditActorDesktop, "", map[string]string{"questions": strconv.Itoa(n)})
+			qCount.SetText(fmt.Sprintf("题目: %d", n))
 			status.SetText("已加载题库")
 		}, w)
 		//fd.SetTitle("选择题库 Excel (.xlsx/.xls)")
@@ -166,32 +216,16 @@ func main() {
 				dialog.ShowError(err, w)
 				return
 			}
-			levels, codes, err := LoadCodesFromExcel(tmp)
+			levels, available, err := loadPrizeCodes(tmp, false)
 			if err != nil {
 				dialog.ShowError(err, w)
 				return
 			}
+			audit(AuditCodes, auditActorDesktop, "", map[string]string{"levels": strconv.Itoa(levels), "available": strconv.Itoa(available)})
 
-			// Filter out codes that have been used today
-			availableCodes := []PrizeCode{}
-			for _, code := range codes {
-				used, err := IsCodeUsedToday(resultsXlsx, code.Code)
-				if err != nil {
-					log.Printf("检查兑换码使用状态错误: %v", err)
-					continue
-				}
-				if !used {
-					availableCodes = append(availableCodes, code)
-				}
-			}
-
-			mutex.Lock()
-			prizeLevels = levels
-			prizeCodes = availableCodes
-			mutex.Unlock()
-
-			codeCount.SetText(fmt.Sprintf("可用兑换码: %d", len(availableCodes)))
-			status.SetText(fmt.Sprintf("已加载 %d 个奖品等级, %d 个可用兑换码", len(levels), len(availableCodes)))
+			codeCount.SetText(fmt.Sprintf("可用兑换码: %d", available))
+			stockLabel.SetText(formatInventory(inventorySnapshot()))
+			status.SetText(fmt.Sprintf("已加载 %d 个奖品等级, %d 个可用兑换码", levels, available))
 		}, w)
 		fd.Show()
 	})
@@ -218,50 +252,45 @@ func main() {
 				dialog.ShowError(err, w)
 				return
 			}
-			resultsXlsx = p
+			setResultPath(p)
 			status.SetText("结果路径已设置: " + p)
 		}, w)
 		//fd.SetTitle("选择结果保存路径 Excel")
 		fd.Show()
 	})
 
-	var btnToggle *widget.Button
+	var btnToggle, btnQR *widget.Button
 	btnToggle = widget.NewButton("启动 Web 服务", func() {
-		if serverRunning {
-			if server != nil {
-				_ = server.Close()
+		switch serverStatus() {
+		case ServerRunning:
+			// 等待已开始答题的人提交后再停止，期间按钮不可用
+			btnToggle.Disable()
+			btnToggle.SetText("正在停止…")
+			status.SetText(fmt.Sprintf("已停止开始新的答题，%d 秒后停止服务", int(drainPeriod.Seconds())))
+			go stopServer(drainPeriod)
+		case ServerStopped:
+			u, err := startServer(func() {
+				fyne.Do(func() {
+					btnToggle.Enable()
+					btnToggle.SetText("启动 Web 服务")
+					status.SetText("服务已停止")
+					rightLinkLabel.SetText(linkPlaceholder)
+				})
+			})
+			if err != nil {
+				dialog.ShowError(err, w)
+				status.SetText("服务启动失败")
+				return
 			}
-			serverRunning = false
-			btnToggle.SetText("启动 Web 服务")
-			status.SetText("服务已停止")
-		} else {
-			mux := http.NewServeMux()
-			// serve templates from embed and static via /static/
-			setupWebHandlers(mux)
-			server = &http.Server{
-				Addr:    listenAddr,
-				Handler: mux,
-			}
-			go func() {
-				serverRunning = true
-				btnToggle.SetText("停止 Web 服务")
-				baseURL = "http://" + localIP() + listenAddr
-				status.SetText("服务运行中，访问: " + baseURL)
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-					log.Println("server error:", err)
-				}
-				serverRunning = false
-				btnToggle.SetText("启动 Web 服务")
-				status.SetText("服务已停止")
-			}()
+			btnToggle.SetText("停止 Web 服务")
+			status.SetText("服务运行中，访问: " + u)
+			// 实际端口可能因端口被占用而改变，重新生成二维码
+			btnQR.OnTapped()
 		}
 	})
 
-	btnQR := widget.NewButton("生成二维码并显示", func() {
-		u := baseURL
-		if u == "" {
-			u = "http://" + localIP() + listenAddr
-		}
+	btnQR = widget.NewButton("生成二维码并显示", func() {
+		u := publicURL()
 		pngBytes, err := generateQRCodeBytes(u + "/identity.html")
 		if err != nil {
 			dialog.ShowError(err, w)
@@ -276,23 +305,351 @@ func main() {
 			qrImg1.Resource = res
 		}
 		qrImg1.Refresh()
+		rightLinkLabel.SetText(u + "/identity.html")
 		status.SetText("二维码生成，访问: " + u + "/identity.html")
 	})
 
-	btnExport := widget.NewButton("显示结果文件路径", func() {
-		p := resultsXlsx
-		if p == "" {
-			p = filepath.Join(dataDir, "records.xlsx")
+	// 二维码地址：从本机网卡地址中选择或填写主机名，留空自动检测
+	btnHost := widget.NewButton("二维码地址", func() {
+		options := []string{}
+		for _, a := range listNetworkAddresses() {
+			label := a.IP + " (" + a.Interface
+			if a.Virtual {
+				label += "，虚拟网卡"
+			}
+			options = append(options, label+")")
+		}
+		mutex.Lock()
+		host, name := advertiseHost, mdnsName
+		if h := mdnsHostLocked(); h != "" {
+			options = append([]string{h + " (mDNS)"}, options...)
+		}
+		mutex.Unlock()
+		hostEntry := widget.NewSelectEntry(options)
+		hostEntry.SetPlaceHolder("自动检测")
+		hostEntry.SetText(host)
+		mdnsEntry := widget.NewEntry()
+		mdnsEntry.SetPlaceHolder("如 quiz-branch01，留空不广播")
+		mdnsEntry.SetText(name)
+		dialog.ShowForm("二维码地址", "保存", "取消", []*widget.FormItem{
+			widget.NewFormItem("IP 或主机名", hostEntry),
+			widget.NewFormItem("mDNS 名称 (.local)", mdnsEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			// mDNS 名称下次启动服务时生效
+			if err := setMDNSName(mdnsEntry.Text); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			// 下拉选项带有网卡名称，只取地址部分
+			host := strings.TrimSpace(hostEntry.Text)
+			if i := strings.Index(host, " ("); i >= 0 {
+				host = host[:i]
+			}
+			u, err := setAdvertiseHost(host)
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			btnQR.OnTapped()
+			status.SetText("访问地址: " + u)
+		}, w)
+	})
+
+	// 导出根证书：安装到工作人员的手机或电脑并信任后，访问 HTTPS 页面不再提示证书不安全
+	btnCA := widget.NewButton("导出 HTTPS 根证书", func() {
+		der, err := caCertificateDER()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		fd := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if wc == nil {
+				return
+			}
+			defer wc.Close()
+			if _, err := wc.Write(der); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			status.SetText("根证书已保存: " + wc.URI().Path())
+		}, w)
+		fd.SetFileName("quiz-ca.crt")
+		fd.Show()
+	})
+
+	btnDashboard := widget.NewButton("实时看板", func() {
+		showDashboardWindow(a)
+	})
+
+	btnRules := widget.NewButton("奖品规则", func() {
+		showPrizeRulesWindow(a, func() {
+			status.SetText("奖品规则已更新")
+		})
+	})
+
+	chkVoucher := widget.NewCheck("中奖时签发兑换凭证二维码", func(on bool) {
+		mutex.Lock()
+		voucherEnabled = on
+		mutex.Unlock()
+	})
+	mutex.Lock()
+	voucherOn := voucherEnabled
+	mutex.Unlock()
+	chkVoucher.SetChecked(voucherOn)
+
+	// 暂停/恢复接收答题，网页管理后台切换时同步勾选状态
+	chkAccepting := widget.NewCheck("接收答题", nil)
+	chkAccepting.SetChecked(true)
+	chkAccepting.OnChanged = func(on bool) {
+		mutex.Lock()
+		changed := acceptingSubmissions != on
+		mutex.Unlock()
+		if changed {
+			setAccepting(on)
+			audit(AuditAccepting, auditActorDesktop, "", map[string]string{"accepting": strconv.FormatBool(on)})
+		}
+	}
+	onAcceptingChange = func(on bool) {
+		fyne.Do(func() {
+			chkAccepting.SetChecked(on)
+			if on {
+				status.SetText("已恢复接收答题")
+			} else {
+				status.SetText("已暂停接收答题")
+			}
+		})
+	}
+
+	// 网页管理后台：在手机或平板上打开，首次使用 admin 和 admin.key 中的初始密码登录
+	btnWebAdmin := widget.NewButton("网页管理后台", func() {
+		key, err := loadAdminPassword()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		u := publicURL()
+		dialog.ShowInformation("网页管理后台", fmt.Sprintf("地址: %s/admin\n用户名: %s\n初始密码: %s（修改后以新密码为准）", u, adminUser, key), w)
+	})
+
+	// 管理员账号：新建账号或重置密码，角色决定网页后台可用的功能
+	btnUsers := widget.NewButton("管理员账号", func() {
+		roleNames := map[string]string{RoleOrganizer: "组织者", RoleClerk: "兑奖员", RoleViewer: "查看者"}
+		userEntry := widget.NewEntry()
+		roleSelect := widget.NewSelect(optionLabels(roleNames, RoleOrganizer, RoleClerk, RoleViewer), nil)
+		roleSelect.SetSelected(roleNames[RoleClerk])
+		passEntry := widget.NewPasswordEntry()
+		passEntry.SetPlaceHolder("密码或 PIN，至少 4 位")
+		dialog.ShowForm("管理员账号", "保存", "取消", []*widget.FormItem{
+			widget.NewFormItem("用户名", userEntry),
+			widget.NewFormItem("角色", roleSelect),
+			widget.NewFormItem("密码", passEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := setAdminUser(userEntry.Text, optionKey(roleNames, roleSelect.Selected), passEntry.Text); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			audit(AuditUserSave, auditActorDesktop, "", map[string]string{"username": strings.TrimSpace(userEntry.Text), "role": optionKey(roleNames, roleSelect.Selected)})
+			status.SetText("账号已保存: " + strings.TrimSpace(userEntry.Text))
+		}, w)
+	})
+
+	// 核验兑换凭证：粘贴扫码得到的凭证内容，使用本机密钥校验，无需联网
+	btnVerify := widget.NewButton("核验兑换凭证", func() {
+		entry := widget.NewMultiLineEntry()
+		entry.SetPlaceHolder("粘贴扫码得到的凭证内容 (QZV1.…)")
+		dialog.ShowForm("核验兑换凭证", "核验", "取消", []*widget.FormItem{
+			widget.NewFormItem("凭证", entry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			key, err := loadVoucherKey()
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			p, err := VerifyVoucher(entry.Text, key)
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if red, done, err := redemptionOf(p.AttemptID); err == nil && done {
+				dialog.ShowInformation("凭证已核销", describeVoucher(p)+"\n\n已于 "+red.Time.Format("2006-01-02 15:04:05")+" 由 "+red.By+" 核销", w)
+				return
+			}
+			if p.Level == "" {
+				dialog.ShowInformation("凭证有效", describeVoucher(p), w)
+				return
+			}
+			dialog.ShowConfirm("凭证有效", describeVoucher(p)+"\n\n是否核销兑奖？", func(redeem bool) {
+				if !redeem {
+					return
+				}
+				if _, _, err := redeemVoucher(entry.Text, auditActorDesktop); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				audit(AuditRedeem, auditActorDesktop, "", map[string]string{"attempt_id": p.AttemptID, "level": p.Level, "code": p.Code})
+				status.SetText("已核销: " + p.AttemptID)
+			}, w)
+		}, w)
+	})
+
+	// 补打凭证：按答题编号重新生成凭证图片或 PDF 并保存
+	btnReprint := widget.NewButton("补打凭证", func() {
+		idEntry := widget.NewEntry()
+		idEntry.SetPlaceHolder("答题编号")
+		formatSelect := widget.NewSelect([]string{"png", "pdf"}, nil)
+		formatSelect.SetSelected("png")
+		dialog.ShowForm("补打凭证", "生成", "取消", []*widget.FormItem{
+			widget.NewFormItem("编号", idEntry),
+			widget.NewFormItem("格式", formatSelect),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			id := strings.TrimSpace(idEntry.Text)
+			b, _, err := certificateForAttempt(id, formatSelect.Selected)
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			fd := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+				if wc == nil {
+					return
+				}
+				defer wc.Close()
+				if _, err := wc.Write(b); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				status.SetText("凭证已保存: " + wc.URI().Path())
+			}, w)
+			fd.SetFileName("certificate-" + id + "." + formatSelect.Selected)
+			fd.Show()
+		}, w)
+	})
+
+	// 导入活动配置：TOML 或 JSON，应用后加载其中的题库和兑换码，并保存为当前配置
+	btnImportCfg := widget.NewButton("导入活动配置", func() {
+		fd := dialog.NewFileOpen(func(rc fyne.URIReadCloser, err error) {
+			if rc == nil {
+				return
+			}
+			data, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			tmp := filepath.Join(a.Storage().RootURI().Path(), "import_event"+rc.URI().Extension())
+			if err := os.WriteFile(tmp, data, 0644); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			cfg, err := LoadEventConfig(tmp)
+			if err == nil {
+				err = applyEventConfig(cfg)
+			}
+			if err == nil {
+				err = loadConfiguredSources(cfg)
+			}
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if err := saveCurrentEventConfig(); err != nil {
+				log.Printf("保存活动配置错误: %v", err)
+			}
+			audit(AuditImportConfig, auditActorDesktop, "", map[string]string{"file": rc.URI().Name()})
+			mutex.Lock()
+			n, low := len(questions), defaultLowStock
+			_, port, _ := net.SplitHostPort(listenAddr)
+			fallback := listenFallback
+			useTLS := tlsEnabled
+			captive := captivePortal
+			mutex.Unlock()
+			qCount.SetText(fmt.Sprintf("题目: %d", n))
+			lowStockEntry.SetText(strconv.Itoa(low))
+			portEntry.SetText(port)
+			chkFallback.SetChecked(fallback)
+			chkTLS.SetChecked(useTLS)
+			chkCaptive.SetChecked(captive)
+			w.SetTitle(currentBranding().AdminTitle)
+			status.SetText("已导入活动配置")
+		}, w)
+		fd.Show()
+	})
+
+	// 导出活动配置：按文件扩展名保存为 TOML (.toml) 或 JSON
+	btnExportCfg := widget.NewButton("导出活动配置", func() {
+		fd := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if wc == nil {
+				return
+			}
+			defer wc.Close()
+			mutex.Lock()
+			cfg := currentEventConfigLocked()
+			mutex.Unlock()
+			b, err := encodeEventConfig(cfg, isTOMLPath(wc.URI().Path()))
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			if _, err := wc.Write(b); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			status.SetText("活动配置已导出: " + wc.URI().Path())
+		}, w)
+		fd.SetFileName("event.toml")
+		fd.Show()
+	})
+
+	// 题目分析报告：每道题的正确率、选项分布、区分度和平均用时，保存为 Excel
+	btnAnalysis := widget.NewButton("题目分析报告", func() {
+		stats, err := itemAnalysis()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
 		}
-		dialog.ShowInformation("结果文件路径", p, w)
+		fd := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if wc == nil {
+				return
+			}
+			defer wc.Close()
+			if err := WriteItemAnalysisExcel(wc, stats); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			status.SetText("题目分析已保存: " + wc.URI().Path())
+		}, w)
+		fd.SetFileName("item-analysis.xlsx")
+		fd.Show()
+	})
+
+	// 答题记录：按条件筛选浏览，查看每次答题的详情
+	btnRecords := widget.NewButton("答题记录", func() {
+		showRecordsWindow(a)
 	})
 
 	// Layout: left sidebar (controls), right content (QR + status) responsive
 	left := container.NewVBox(
 		widget.NewLabelWithStyle("反诈答题 - 管理后台", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		status, container.NewHBox(qCount, codeCount),
+		stockLabel,
+		container.NewBorder(nil, nil, widget.NewLabel("低库存阈值:"), nil, lowStockEntry),
+		container.NewBorder(nil, nil, widget.NewLabel("端口:"), nil, portEntry),
+		chkFallback, chkTLS, chkCaptive,
 		layout.NewSpacer(),
-		btnLoadQ, btnLoadC, btnLoadPath, btnToggle, btnQR, btnExport,
+		btnLoadQ, btnLoadC, btnRules, btnLoadPath, btnToggle, btnQR, btnHost, btnCA, btnDashboard, btnAnalysis, btnRecords,
+		chkAccepting, chkVoucher, btnVerify, btnReprint, btnImportCfg, btnExportCfg, btnWebAdmin, btnUsers,
 	)
 
 	// 确保 qrImg 的 FillMode 为 ImageFillContain，保证图片按比例缩放
@@ -303,7 +660,6 @@ func main() {
 
 	// 在右侧放置 QR 与文本信息
 	rightInfoLabel := widget.NewLabel("访问链接 (手机扫码或点击):")
-	rightLinkLabel := widget.NewLabelWithStyle("http://<ip>:8080/identity.html", fyne.TextAlignLeading, fyne.TextStyle{})
 	right := container.NewVBox(
 		qrSquare,
 		container.NewVBox(rightInfoLabel, rightLinkLabel),
@@ -319,6 +675,14 @@ func main() {
 	//w.SetContent(content)
 	w.Canvas().SetOnTypedKey(func(ev *fyne.KeyEvent) {}) // noop to ensure canvas exists
 
+	// 关闭窗口时等待进行中的提交写完再退出，正在平稳停止服务时等到停止完成
+	w.SetCloseIntercept(func() {
+		status.SetText("正在停止服务…")
+		go func() {
+			stopServer(0)
+			fyne.Do(w.Close)
+		}()
+	})
 	w.ShowAndRun()
 	fmt.Println("DPI Scale =", w.Canvas().Scale())
 
@@ -354,401 +718,6 @@ func (r *resizeListener) CanvasResized(size fyne.Size) {
 	r.qr.Refresh()
 }
 
-func localIP() string {
-	return getLocalIP()
-}
-
-// getLocalIP 获取本地IP地址（兼容Android 10+）
-func getLocalIP() string {
-	// 方法1: 通过连接外部DNS服务器获取本机IP
-	if ip := getIPFromDNS(); ip != "" {
-		return ip
-	}
-	// 方法2: 尝试常见的局域网网卡
-	if ip := getIPFromInterfaces(); ip != "" {
-		return ip
-	}
-
-	// 方法3: 使用net.LookupHost获取主机名对应的IP
-	if ip := getIPFromHostname(); ip != "" {
-		return ip
-	}
-
-	return "127.0.0.1"
-}
-
-// getIPFromDNS 通过连接DNS服务器获取本机IP
-func getIPFromDNS() string {
-	// 尝试多个公共DNS服务器
-	dnsServers := []string{
-		"8.8.8.8:53",         // Google DNS
-		"1.1.1.1:53",         // Cloudflare DNS
-		"208.67.222.222:53",  // OpenDNS
-		"114.114.114.114:53", // 114 DNS
-	}
-	for _, dnsServer := range dnsServers {
-		conn, err := net.Dial("udp", dnsServer)
-		if err != nil {
-			continue
-		}
-
-		localAddr := conn.LocalAddr().(*net.UDPAddr)
-		ip := localAddr.IP.String()
-		conn.Close()
-
-		// 检查是否是有效的局域网IP
-		if isValidLocalIP(ip) {
-			return ip
-		}
-	}
-	return ""
-}
-
-// getIPFromInterfaces 从网络接口获取IP
-func getIPFromInterfaces() string {
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return ""
-	}
-	for _, iface := range interfaces {
-		// 跳过回环接口和未启用接口
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
-		}
-
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-
-			if ip == nil || ip.IsLoopback() {
-				continue
-			}
-
-			ip = ip.To4()
-			if ip == nil {
-				continue
-			}
-
-			ipStr := ip.String()
-			if isValidLocalIP(ipStr) {
-				return ipStr
-			}
-		}
-	}
-	return ""
-}
-
-// getIPFromHostname 通过主机名获取IP
-func getIPFromHostname() string {
-	addrs, err := net.LookupHost("localhost")
-	if err != nil {
-		return ""
-	}
-	for _, addr := range addrs {
-		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
-			ipStr := ip.String()
-			if isValidLocalIP(ipStr) {
-				return ipStr
-			}
-		}
-	}
-	return ""
-}
-
-// isValidLocalIP 检查是否是有效的局域网IP
-func isValidLocalIP(ip string) bool {
-	if ip == "127.0.0.1" || ip == "::1" || ip == "0.0.0.0" {
-		return false
-	}
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return false
-	}
-
-	// 检查私有IP地址范围
-	privateRanges := []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-		"169.254.0.0/16", // 链路本地地址
-	}
-
-	for _, cidr := range privateRanges {
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			continue
-		}
-		if network.Contains(parsedIP) {
-			return true
-		}
-	}
-
-	return false
-}
-
-// getNetworkInfo 获取网络信息（主函数）
-func getNetworkInfo() string {
-	ip := getLocalIP()
-	// 如果获取不到有效IP，提供使用说明
-	if ip == "127.0.0.1" {
-		return "无法自动获取IP，请手动查看手机IP地址"
-	}
-
-	return ip
-}
-
-// setupWebHandlers mounts template endpoints and static resource handler (serves from embed)
-func setupWebHandlers(mux *http.ServeMux) {
-	// templates
-	tStart := template.Must(template.ParseFS(webFS, "web/start.html"))
-	tIdentity := template.Must(template.ParseFS(webFS, "web/identity.html"))
-	tQuiz := template.Must(template.ParseFS(webFS, "web/quiz.html"))
-	tReward := template.Must(template.ParseFS(webFS, "web/reward.html"))
-
-	// root -> start page
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_ = tStart.Execute(w, nil)
-	})
-	// API: 获取网络信息
-	mux.HandleFunc("/api/network-info", func(w http.ResponseWriter, r *http.Request) {
-		ip := getLocalIP()
-		info := map[string]string{
-			"ip":     ip,
-			"url":    "http://" + ip + listenAddr,
-			"status": "ready",
-		}
-
-		if ip == "127.0.0.1" {
-			info["message"] = "无法自动获取IP，请手动查看手机IP地址"
-			info["status"] = "manual_required"
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(info)
-	})
-
-	// API: check-user 检查用户是否已经答题
-	mux.HandleFunc("/api/check-user", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var req struct {
-			PhoneHash string `json:"phone_hash"`
-			IdHash    string `json:"id_hash"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request:"+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		if req.PhoneHash == "" || req.IdHash == "" {
-			http.Error(w, "user_hash is required", http.StatusBadRequest)
-			return
-		}
-
-		mutex.Lock()
-		defer mutex.Unlock()
-
-		answered, err := CheckUserAnswered(resultsXlsx, req.PhoneHash, req.IdHash)
-		if err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]bool{
-			"answered": answered,
-		})
-	})
-
-	mux.HandleFunc("/identity.html", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_ = tIdentity.Execute(w, nil)
-	})
-	mux.HandleFunc("/quiz.html", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_ = tQuiz.Execute(w, nil)
-	})
-	mux.HandleFunc("/reward.html", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		_ = tReward.Execute(w, nil)
-	})
-
-	// static resources served from embed at /static/
-	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
-		// strip /static/
-		path := strings.TrimPrefix(r.URL.Path, "/static/")
-		if path == "" {
-			http.NotFound(w, r)
-			return
-		}
-		// read from embed
-		b, err := webFS.ReadFile("web/static/" + path)
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		ext := strings.ToLower(filepath.Ext(path))
-		switch ext {
-		case ".css":
-			w.Header().Set("Content-Type", "text/css; charset=utf-8")
-		case ".js":
-			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
-		case ".png":
-			w.Header().Set("Content-Type", "image/png")
-		case ".jpg", ".jpeg":
-			w.Header().Set("Content-Type", "image/jpeg")
-		case ".html":
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		default:
-			w.Header().Set("Content-Type", "application/octet-stream")
-		}
-		_, _ = w.Write(b)
-	})
-	// API: start-info
-	mux.HandleFunc("/api/start-info", func(w http.ResponseWriter, r *http.Request) {
-		u := baseURL
-		if u == "" {
-			u = "http://" + localIP() + listenAddr
-		}
-
-		qb64, _ := generateQRCodeBase64(u + "/identity.html")
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"exam_url": u + "/identity.html",
-			"qrcode":   "data:image/png;base64," + qb64,
-		})
-	})
-	// API: questions (returns shuffled)
-	mux.HandleFunc("/api/questions", func(w http.ResponseWriter, r *http.Request) {
-		mutex.Lock()
-		arr := make([]Question, len(questions))
-		copy(arr, questions)
-		mutex.Unlock()
-		// shuffle
-		for i := range arr {
-			j := int(time.Now().UnixNano()) % (len(arr) + 1)
-			if j >= len(arr) {
-				j = 0
-			}
-			arr[i], arr[j] = arr[j], arr[i]
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(arr)
-	})
-
-	// API: submit (新的奖品发放逻辑)
-	mux.HandleFunc("/api/submit", func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			Name       string           `json:"name"`
-			Phone      string           `json:"phone"`
-			IdCard     string           `json:"idCard"`
-			MaskName   string           `json:"mask_name"`
-			MaskPhone  string           `json:"mask_phone"`
-			MaskIdCard string           `json:"mask_idCard"`
-			Answers    map[string][]int `json:"answers"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request:"+err.Error(), http.StatusBadRequest)
-			return
-		}
-		if strings.TrimSpace(req.Name) == "" || len(req.Phone) != 64 || len(req.IdCard) != 64 {
-			http.Error(w, "invalid info", http.StatusBadRequest)
-			return
-		}
-
-		mutex.Lock()
-		defer mutex.Unlock()
-
-		total := 0
-		score := 0
-		detail := map[string]interface{}{}
-		for _, q := range questions {
-			s := 1
-			if q.Score > 0 {
-				s = q.Score
-			}
-			total += s
-			given := req.Answers[q.ID]
-			gotScore, ok := gradeQuestion(q, given)
-			if ok {
-				score += gotScore
-			}
-			givenLabels := []string{}
-			for _, gi := range given {
-				if gi >= 0 && gi < len(q.Options) {
-					givenLabels = append(givenLabels, q.Options[gi])
-				}
-			}
-			detail[q.ID] = map[string]interface{}{"given": givenLabels, "correct": gotScore > 0}
-		}
-
-		// 新的奖品发放逻辑
-		var assigned string
-		var prizeLevel string
-
-		if total > 0 && len(prizeCodes) > 0 {
-			// 计算百分比分数
-			percentage := int(float64(score) / float64(total) * 100)
-
-			// 按奖品等级从高到低尝试分配
-			for _, levelConfig := range prizeLevels {
-				if percentage >= levelConfig.Score {
-					// 尝试分配该等级的奖品
-					assigned, prizeLevel = assignPrizeByLevel(levelConfig.Level)
-					if assigned != "" {
-						break // 成功分配到奖品，退出循环
-					}
-				}
-			}
-		}
-
-		if assigned != "" {
-			usedCodes = append(usedCodes, assigned)
-		}
-
-		if resultsXlsx == "" {
-			resultsXlsx = filepath.Join(dataDir, "records.xlsx")
-		}
-		_ = SaveResultToExcel(resultsXlsx, req.Name, req.Phone, req.IdCard, req.MaskName, req.MaskPhone, req.MaskIdCard, score, total, assigned, detail)
-
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"score":       score,
-			"total":       total,
-			"percentage":  int(float64(score) / float64(total) * 100),
-			"code":        assigned,
-			"prize_level": prizeLevel,
-		})
-	})
-}
-
-// assignPrizeByLevel 按等级分配奖品
-func assignPrizeByLevel(level string) (string, string) {
-	for i, prize := range prizeCodes {
-		if prize.Level == level && !prize.Used {
-			// 标记为已使用并从可用列表中移除
-			prizeCodes[i].Used = true
-			return prize.Code, level
-		}
-	}
-	return "", ""
-}
-
 func (s *squareLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
 	if len(objects) == 0 {
 		return
@@ -781,43 +750,3 @@ func (s *squareLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
 	}
 	return fyne.NewSize(side, side)
 }
-
-//func generateQRCodeBase64(url string) (string, error) {
-//	pngBytes, err := qrcode.Encode(url, qrcode.Medium, 512)
-//	if err != nil {
-//		return "", err
-//	}
-//	return base64.StdEncoding.EncodeToString(pngBytes), nil
-//}
-
-func generateQRCodeBytes(url string) ([]byte, error) {
-	return qrcode.Encode(url, qrcode.Medium, 512)
-}
-
-func gradeQuestion(q Question, given []int) (int, bool) {
-	if q.Type == "single" || q.Type == "judge" {
-		if len(q.Answer) > 0 && len(given) == 1 && q.Answer[0] == given[0] {
-			if q.Score > 0 {
-				return q.Score, true
-			}
-			return 1, true
-		}
-		return 0, false
-	}
-	if len(q.Answer) != len(given) {
-		return 0, false
-	}
-	m := map[int]bool{}
-	for _, a := range q.Answer {
-		m[a] = true
-	}
-	for _, g := range given {
-		if !m[g] {
-			return 0, false
-		}
-	}
-	if q.Score > 0 {
-		return q.Score, true
-	}
-	return 1, true
-}
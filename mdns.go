@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// mdnsTTL mDNS 记录的有效时间（秒）
+	mdnsTTL = 120
+	// mdnsPath DNS-SD TXT 记录中的入口页面
+	mdnsPath = "/identity.html"
+)
+
+// mdnsGroup mDNS 组播地址
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+var (
+	// mdnsName 通过 mDNS 广播的主机名（不含 .local），为空时不广播，调用方需持有 mutex
+	mdnsName string
+	// mdnsResponder 正在运行的 mDNS 广播，服务停止时关闭，调用方需持有 serverMu
+	mdnsResponder *mdnsServer
+)
+
+// validateMDNSName 检查 mDNS 主机名：字母、数字和连字符，不含 .local
+func validateMDNSName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > 63 || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return fmt.Errorf("mDNS 主机名 %q 无效", name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("mDNS 主机名 %q 只能包含字母、数字和连字符，不需要填写 .local", name)
+		}
+	}
+	return nil
+}
+
+// mdnsHostLocked mDNS 主机名对应的完整域名，如 quiz-branch01.local，未启用时为空，调用方需持有 mutex
+func mdnsHostLocked() string {
+	if mdnsName == "" {
+		return ""
+	}
+	return strings.ToLower(mdnsName) + ".local"
+}
+
+// setMDNSName 设置 mDNS 主机名（为空时不广播，下次启动服务时生效），并保存到活动配置
+func setMDNSName(name string) error {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".local")
+	if err := validateMDNSName(name); err != nil {
+		return err
+	}
+	mutex.Lock()
+	mdnsName = name
+	mutex.Unlock()
+	return saveCurrentEventConfig()
+}
+
+// mdnsServicesName DNS-SD 服务类型枚举的查询名称，回答本机提供的服务类型
+const mdnsServicesName = "_services._dns-sd._udp.local."
+
+// mdnsLegacyTTL 回复普通 DNS 客户端（非 5353 端口）时 TTL 的上限
+const mdnsLegacyTTL = 10
+
+// mdnsServer 回答局域网内对本机主机名和答题服务（DNS-SD）的 mDNS 查询
+type mdnsServer struct {
+	conn     *net.UDPConn
+	host     dnsmessage.Name // quiz-branch01.local.
+	instance dnsmessage.Name // quiz-branch01._http._tcp.local.
+	service  dnsmessage.Name // _http._tcp.local.
+	port     uint16
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// startMDNS 开始广播 name.local 和答题服务，port 为实际监听端口
+func startMDNS(name string, port int, https bool) (*mdnsServer, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("无法启动 mDNS: %v", err)
+	}
+	proto := "_http._tcp.local."
+	if https {
+		proto = "_https._tcp.local."
+	}
+	name = strings.ToLower(name)
+	s := &mdnsServer{
+		conn:     conn,
+		host:     dnsmessage.MustNewName(name + ".local."),
+		instance: dnsmessage.MustNewName(name + "." + proto),
+		service:  dnsmessage.MustNewName(proto),
+		port:     uint16(port),
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(2)
+	go s.serve()
+	go s.announce()
+	return s, nil
+}
+
+// close 发送 TTL 为 0 的记录通知其它设备删除缓存，然后停止广播
+func (s *mdnsServer) close() {
+	close(s.done)
+	if msg, err := s.response(0, nil, 0, nil, false); err == nil {
+		_, _ = s.conn.WriteToUDP(msg, mdnsGroup)
+	}
+	_ = s.conn.Close()
+	s.wg.Wait()
+}
+
+// announce 启动时主动发送几次记录，方便其它设备更新缓存
+func (s *mdnsServer) announce() {
+	defer s.wg.Done()
+	for i := 0; i < 3; i++ {
+		if msg, err := s.response(0, nil, mdnsTTL, nil, false); err == nil {
+			_, _ = s.conn.WriteToUDP(msg, mdnsGroup)
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Duration(1<<i) * time.Second):
+		}
+	}
+}
+
+// serve 读取查询并回答与本机有关的问题
+func (s *mdnsServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				log.Printf("mDNS 读取错误: %v", err)
+			}
+			return
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.Response {
+			continue
+		}
+		qs, err := p.AllQuestions()
+		if err != nil {
+			continue
+		}
+		answer := false
+		for _, q := range qs {
+			if s.matches(q) {
+				answer = true
+				break
+			}
+		}
+		if !answer {
+			continue
+		}
+		// 不是从 5353 端口发出的查询（普通 DNS 客户端）直接回复给对方，其余按规定组播回复
+		legacy := src.Port != mdnsGroup.Port
+		msg, err := s.response(h.ID, src.IP, mdnsTTL, qs, legacy)
+		if err != nil {
+			continue
+		}
+		dst := mdnsGroup
+		if legacy {
+			dst = src
+		}
+		_, _ = s.conn.WriteToUDP(msg, dst)
+	}
+}
+
+// matches 查询是否与本机主机名或答题服务有关
+func (s *mdnsServer) matches(q dnsmessage.Question) bool {
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case name == strings.ToLower(s.host.String()):
+		return q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL
+	case name == s.service.String(), name == mdnsServicesName:
+		return q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL
+	case name == strings.ToLower(s.instance.String()):
+		return true
+	}
+	return false
+}
+
+// response 生成包含主机地址和 DNS-SD 记录的回复，地址优先选择与查询方同一网段的本机地址。
+// qs 为收到的问题，其中有服务类型枚举时回答服务类型；legacy 为回复普通 DNS 客户端，
+// 按 RFC 6762 第 6.7 节回显问题、不设置 cache-flush 位并缩短 TTL
+func (s *mdnsServer) response(id uint16, peer net.IP, ttl uint32, qs []dnsmessage.Question, legacy bool) ([]byte, error) {
+	ip := localIPFor(peer)
+	if ip == nil {
+		return nil, fmt.Errorf("no local address")
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+	if legacy {
+		if err := b.StartQuestions(); err != nil {
+			return nil, err
+		}
+		for _, q := range qs {
+			if err := b.Question(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	// 主机名和服务实例的记录是本机独有的，设置 cache-flush 位
+	const cacheFlush = dnsmessage.Class(1 << 15)
+	unique := dnsmessage.ResourceHeader{Class: dnsmessage.ClassINET | cacheFlush, TTL: ttl}
+	shared := dnsmessage.ResourceHeader{Class: dnsmessage.ClassINET, TTL: ttl}
+	if legacy {
+		unique.Class = dnsmessage.ClassINET
+		if ttl > mdnsLegacyTTL {
+			unique.TTL, shared.TTL = mdnsLegacyTTL, mdnsLegacyTTL
+		}
+	}
+
+	for _, q := range qs {
+		if strings.EqualFold(q.Name.String(), mdnsServicesName) {
+			h := shared
+			h.Name = dnsmessage.MustNewName(mdnsServicesName)
+			if err := b.PTRResource(h, dnsmessage.PTRResource{PTR: s.service}); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	h := unique
+	h.Name = s.host
+	var a [4]byte
+	copy(a[:], ip.To4())
+	if err := b.AResource(h, dnsmessage.AResource{A: a}); err != nil {
+		return nil, err
+	}
+	h = shared
+	h.Name = s.service
+	if err := b.PTRResource(h, dnsmessage.PTRResource{PTR: s.instance}); err != nil {
+		return nil, err
+	}
+	h = unique
+	h.Name = s.instance
+	if err := b.SRVResource(h, dnsmessage.SRVResource{Target: s.host, Port: s.port}); err != nil {
+		return nil, err
+	}
+	if err := b.TXTResource(h, dnsmessage.TXTResource{TXT: []string{"path=" + mdnsPath}}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testMDNSServer 不监听网络的 mDNS 广播，只用于生成回复
+func testMDNSServer() *mdnsServer {
+	return &mdnsServer{
+		host:     dnsmessage.MustNewName("quiz-test.local."),
+		instance: dnsmessage.MustNewName("quiz-test._http._tcp.local."),
+		service:  dnsmessage.MustNewName("_http._tcp.local."),
+		port:     8080,
+	}
+}
+
+func TestMDNSMatches(t *testing.T) {
+	s := testMDNSServer()
+	q := func(name string, typ dnsmessage.Type) dnsmessage.Question {
+		return dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET}
+	}
+	tests := []struct {
+		q    dnsmessage.Question
+		want bool
+	}{
+		{q("quiz-test.local.", dnsmessage.TypeA), true},
+		{q("Quiz-Test.local.", dnsmessage.TypeA), true},
+		{q("quiz-test.local.", dnsmessage.TypeALL), true},
+		{q("quiz-test.local.", dnsmessage.TypeAAAA), false},
+		{q("_http._tcp.local.", dnsmessage.TypePTR), true},
+		{q("_http._tcp.local.", dnsmessage.TypeA), false},
+		{q(mdnsServicesName, dnsmessage.TypePTR), true},
+		{q("quiz-test._http._tcp.local.", dnsmessage.TypeSRV), true},
+		{q("other.local.", dnsmessage.TypeA), false},
+		{q("_ipp._tcp.local.", dnsmessage.TypePTR), false},
+	}
+	for _, tt := range tests {
+		if got := s.matches(tt.q); got != tt.want {
+			t.Errorf("matches(%s %s) = %v, want %v", tt.q.Name, tt.q.Type, got, tt.want)
+		}
+	}
+}
+
+func TestMDNSResponse(t *testing.T) {
+	s := testMDNSServer()
+	hostQ := dnsmessage.Question{Name: s.host, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}
+	servicesQ := dnsmessage.Question{Name: dnsmessage.MustNewName(mdnsServicesName), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}
+	const cacheFlush = dnsmessage.Class(1 << 15)
+
+	tests := []struct {
+		name      string
+		qs        []dnsmessage.Question
+		legacy    bool
+		questions int
+		answers   []dnsmessage.Type
+		ttl       uint32
+		flush     bool // 主机名记录是否设置 cache-flush 位
+	}{
+		{"host", []dnsmessage.Question{hostQ}, false, 0,
+			[]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypePTR, dnsmessage.TypeSRV, dnsmessage.TypeTXT}, mdnsTTL, true},
+		{"service enumeration", []dnsmessage.Question{servicesQ}, false, 0,
+			[]dnsmessage.Type{dnsmessage.TypePTR, dnsmessage.TypeA, dnsmessage.TypePTR, dnsmessage.TypeSRV, dnsmessage.TypeTXT}, mdnsTTL, true},
+		{"legacy client", []dnsmessage.Question{hostQ}, true, 1,
+			[]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypePTR, dnsmessage.TypeSRV, dnsmessage.TypeTXT}, mdnsLegacyTTL, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := s.response(0x1234, net.IPv4(127, 0, 0, 9), mdnsTTL, tt.qs, tt.legacy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m dnsmessage.Message
+			if err := m.Unpack(b); err != nil {
+				t.Fatal(err)
+			}
+			if m.ID != 0x1234 || !m.Response || !m.Authoritative || len(m.Questions) != tt.questions {
+				t.Fatalf("header = %+v, %d questions", m.Header, len(m.Questions))
+			}
+			if len(m.Answers) != len(tt.answers) {
+				t.Fatalf("answers = %d, want %d", len(m.Answers), len(tt.answers))
+			}
+			for i, a := range m.Answers {
+				if a.Header.Type != tt.answers[i] || a.Header.TTL != tt.ttl {
+					t.Fatalf("answer %d = %s ttl %d, want %s ttl %d", i, a.Header.Type, a.Header.TTL, tt.answers[i], tt.ttl)
+				}
+				switch r := a.Body.(type) {
+				case *dnsmessage.AResource:
+					if r.A != [4]byte{127, 0, 0, 1} {
+						t.Fatalf("A = %v, want the loopback address on the peer's network", r.A)
+					}
+					if flush := a.Header.Class&cacheFlush != 0; flush != tt.flush {
+						t.Fatalf("A cache-flush = %v, want %v", flush, tt.flush)
+					}
+				case *dnsmessage.SRVResource:
+					if r.Port != 8080 || r.Target != s.host {
+						t.Fatalf("SRV = %+v", r)
+					}
+				case *dnsmessage.TXTResource:
+					if len(r.TXT) != 1 || r.TXT[0] != "path="+mdnsPath {
+						t.Fatalf("TXT = %v", r.TXT)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricBuckets 耗时直方图的分桶（秒）
+var metricBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram 累计耗时分布，counts[i] 为落在第 i 个分桶（不累加）的次数，最后一个为超过最大分桶的次数
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// observe 记录一次耗时
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(metricBuckets)+1)
+	}
+	i := sort.SearchFloat64s(metricBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// httpMetricKey 按路由、方法和状态码统计请求数
+type httpMetricKey struct {
+	route, method string
+	code          int
+}
+
+// storageMetricKey 按存储方式和操作统计耗时
+type storageMetricKey struct {
+	backend, op string
+}
+
+var (
+	// metricsMu 保护以下统计数据，只在更新和输出时短暂持有，不会再去取 mutex
+	metricsMu        sync.Mutex
+	metricsStarted   = time.Now()
+	attemptsStarted  uint64
+	attemptsSubmit   uint64
+	prizesIssued     = map[string]uint64{}
+	httpRequests     = map[httpMetricKey]uint64{}
+	httpDurations    = map[string]*histogram{}
+	storageDurations = map[storageMetricKey]*histogram{}
+
+	// metricsToken 访问 /metrics 需要的令牌（Authorization: Bearer），为空时只允许本机访问，调用方需持有 mutex
+	metricsToken string
+)
+
+// countAttemptStarted 记录一次开始答题
+func countAttemptStarted() {
+	metricsMu.Lock()
+	attemptsStarted++
+	metricsMu.Unlock()
+}
+
+// countSubmission 记录一次提交，发放了奖品时按等级计数
+func countSubmission(award Award) {
+	metricsMu.Lock()
+	attemptsSubmit++
+	if award.Level != "" {
+		prizesIssued[award.Level]++
+	}
+	metricsMu.Unlock()
+}
+
+// observeStorage 记录一次结果存储操作的耗时
+func observeStorage(backend, op string, start time.Time) {
+	metricsMu.Lock()
+	k := storageMetricKey{backend, op}
+	h := storageDurations[k]
+	if h == nil {
+		h = &histogram{}
+		storageDurations[k] = h
+	}
+	h.observe(time.Since(start).Seconds())
+	metricsMu.Unlock()
+}
+
+// timedStore 记录每次读写耗时的结果存储
+type timedStore struct {
+	ResultStore
+	backend string
+}
+
+func (s timedStore) Append(rec ResultRecord) error {
+	defer observeStorage(s.backend, "append", time.Now())
+	return s.ResultStore.Append(rec)
+}
+
+func (s timedStore) Records() ([]ResultRecord, error) {
+	defer observeStorage(s.backend, "records", time.Now())
+	return s.ResultStore.Records()
+}
+
+func (s timedStore) Flush() error {
+	defer observeStorage(s.backend, "flush", time.Now())
+	return s.ResultStore.Flush()
+}
+
+// metricMethod 请求方法的标签值，不常见的方法统一为 other，避免任意方法名产生无限多的序列
+func metricMethod(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return m
+	}
+	return "other"
+}
+
+// metricsHandler 按路由统计请求数和耗时，路由取 mux 中匹配的模式，避免按原始路径产生过多的序列
+func metricsHandler(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		code := rec.status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		metricsMu.Lock()
+		httpRequests[httpMetricKey{route, metricMethod(r.Method), code}]++
+		h := httpDurations[route]
+		if h == nil {
+			h = &histogram{}
+			httpDurations[route] = h
+		}
+		h.observe(time.Since(start).Seconds())
+		metricsMu.Unlock()
+	})
+}
+
+// metricLabel 转义标签值
+func metricLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat 按 Prometheus 文本格式输出数字
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeHistogram 输出一个直方图，labels 为已格式化的其它标签（如 route="/api/submit"）
+func writeHistogram(b *bytes.Buffer, name, labels string, h *histogram) {
+	var cum uint64
+	for i, le := range metricBuckets {
+		cum += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), cum)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// writeMetrics 按 Prometheus 文本格式输出全部指标
+func writeMetrics(b *bytes.Buffer) {
+	mutex.Lock()
+	stocks := inventorySnapshotLocked()
+	available, accepting, nq := 0, acceptingSubmissions, len(questions)
+	for _, c := range prizeCodes {
+		if !c.Used {
+			available++
+		}
+	}
+	mutex.Unlock()
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	b.WriteString("# HELP process_start_time_seconds Start time of the process since unix epoch in seconds.\n")
+	b.WriteString("# TYPE process_start_time_seconds gauge\n")
+	fmt.Fprintf(b, "process_start_time_seconds %d\n", metricsStarted.Unix())
+
+	b.WriteString("# HELP quiz_attempts_started_total Quizzes started (questions served).\n")
+	b.WriteString("# TYPE quiz_attempts_started_total counter\n")
+	fmt.Fprintf(b, "quiz_attempts_started_total %d\n", attemptsStarted)
+	b.WriteString("# HELP quiz_attempts_submitted_total Quiz answer sheets submitted.\n")
+	b.WriteString("# TYPE quiz_attempts_submitted_total counter\n")
+	fmt.Fprintf(b, "quiz_attempts_submitted_total %d\n", attemptsSubmit)
+
+	b.WriteString("# HELP quiz_prizes_issued_total Prizes issued, by prize level.\n")
+	b.WriteString("# TYPE quiz_prizes_issued_total counter\n")
+	levels := make([]string, 0, len(prizesIssued))
+	for l := range prizesIssued {
+		levels = append(levels, l)
+	}
+	sort.Strings(levels)
+	for _, l := range levels {
+		fmt.Fprintf(b, "quiz_prizes_issued_total{level=\"%s\"} %d\n", metricLabel(l), prizesIssued[l])
+	}
+
+	b.WriteString("# HELP quiz_prize_remaining Prize codes or items remaining, by prize level.\n")
+	b.WriteString("# TYPE quiz_prize_remaining gauge\n")
+	for _, s := range stocks {
+		fmt.Fprintf(b, "quiz_prize_remaining{level=\"%s\"} %d\n", metricLabel(s.Level), s.Remaining)
+	}
+	b.WriteString("# HELP quiz_prize_codes_available Unused prize codes across all levels.\n")
+	b.WriteString("# TYPE quiz_prize_codes_available gauge\n")
+	fmt.Fprintf(b, "quiz_prize_codes_available %d\n", available)
+
+	b.WriteString("# HELP quiz_accepting_submissions Whether the quiz is accepting submissions (1) or paused (0).\n")
+	b.WriteString("# TYPE quiz_accepting_submissions gauge\n")
+	fmt.Fprintf(b, "quiz_accepting_submissions %d\n", map[bool]int{false: 0, true: 1}[accepting])
+	b.WriteString("# HELP quiz_questions Questions in the loaded question bank.\n")
+	b.WriteString("# TYPE quiz_questions gauge\n")
+	fmt.Fprintf(b, "quiz_questions %d\n", nq)
+
+	b.WriteString("# HELP quiz_http_requests_total HTTP requests, by route, method and status code.\n")
+	b.WriteString("# TYPE quiz_http_requests_total counter\n")
+	keys := make([]httpMetricKey, 0, len(httpRequests))
+	for k := range httpRequests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "quiz_http_requests_total{route=\"%s\",method=\"%s\",code=\"%d\"} %d\n",
+			metricLabel(k.route), metricLabel(k.method), k.code, httpRequests[k])
+	}
+
+	b.WriteString("# HELP quiz_http_request_duration_seconds HTTP request latency, by route.\n")
+	b.WriteString("# TYPE quiz_http_request_duration_seconds histogram\n")
+	routes := make([]string, 0, len(httpDurations))
+	for r := range httpDurations {
+		routes = append(routes, r)
+	}
+	sort.Strings(routes)
+	for _, r := range routes {
+		writeHistogram(b, "quiz_http_request_duration_seconds", fmt.Sprintf("route=\"%s\"", metricLabel(r)), httpDurations[r])
+	}
+
+	b.WriteString("# HELP quiz_storage_operation_duration_seconds Result storage operation latency, by backend and operation.\n")
+	b.WriteString("# TYPE quiz_storage_operation_duration_seconds histogram\n")
+	skeys := make([]storageMetricKey, 0, len(storageDurations))
+	for k := range storageDurations {
+		skeys = append(skeys, k)
+	}
+	sort.Slice(skeys, func(i, j int) bool {
+		if skeys[i].backend != skeys[j].backend {
+			return skeys[i].backend < skeys[j].backend
+		}
+		return skeys[i].op < skeys[j].op
+	})
+	for _, k := range skeys {
+		writeHistogram(b, "quiz_storage_operation_duration_seconds",
+			fmt.Sprintf("backend=\"%s\",op=\"%s\"", metricLabel(k.backend), metricLabel(k.op)), storageDurations[k])
+	}
+}
+
+// setupMetricsHandlers Prometheus 文本格式的监控指标，设置了 metrics_token 时需要 Authorization: Bearer <token>，
+// 未设置时只允许本机访问，避免参与者看到奖品库存
+func setupMetricsHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		mutex.Lock()
+		token := metricsToken
+		mutex.Unlock()
+		if token == "" {
+			if ip := net.ParseIP(clientIP(r)); ip == nil || !ip.IsLoopback() {
+				writeError(w, "未设置 metrics_token 时只允许本机访问", http.StatusForbidden)
+				return
+			}
+		} else {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				writeError(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		var b bytes.Buffer
+		writeMetrics(&b)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(b.Bytes())
+	})
+}
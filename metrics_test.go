@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	for _, v := range []float64{0.001, 0.005, 0.3, 20} {
+		h.observe(v)
+	}
+	var b bytes.Buffer
+	writeHistogram(&b, "x", `route="/"`, &h)
+	out := b.String()
+	for _, want := range []string{
+		`x_bucket{route="/",le="0.005"} 2`, // 分桶上限包含等于的值
+		`x_bucket{route="/",le="0.25"} 2`,
+		`x_bucket{route="/",le="0.5"} 3`,
+		`x_bucket{route="/",le="10"} 3`,
+		`x_bucket{route="/",le="+Inf"} 4`,
+		`x_count{route="/"} 4`,
+		`x_sum{route="/"} 20.306`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("histogram output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// metricLine Prometheus 文本格式中的一行样本
+var metricLine = regexp.MustCompile(`^([a-z_]+)(\{[a-z_]+="(\\.|[^"\\])*"(,[a-z_]+="(\\.|[^"\\])*")*\})? [0-9.e+-]+$`)
+
+func TestMetricsExposition(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/metrics-test", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	setupMetricsHandlers(mux)
+	h := metricsHandler(mux, mux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/metrics-test", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/api/metrics-test", nil))
+	countSubmission(Award{Level: `测试"等级`})
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("/metrics = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	out := w.Body.String()
+	for _, want := range []string{
+		`quiz_http_requests_total{route="/api/metrics-test",method="GET",code="418"} 1`,
+		`quiz_http_requests_total{route="/api/metrics-test",method="other",code="418"} 1`,
+		`quiz_http_request_duration_seconds_count{route="/api/metrics-test"} 2`,
+		`quiz_prizes_issued_total{level="测试\"等级"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("/metrics missing %q", want)
+		}
+	}
+
+	// 每个样本都有合法的格式，且所属指标声明了 TYPE
+	typed := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			typed[strings.Fields(name)[0]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		m := metricLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		family := m[1]
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if base := strings.TrimSuffix(family, suffix); base != family && typed[base] {
+				family = base
+			}
+		}
+		if !typed[family] {
+			t.Fatalf("sample %q has no TYPE line", line)
+		}
+	}
+}
+
+func TestMetricsAccess(t *testing.T) {
+	mutex.Lock()
+	saved := metricsToken
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		metricsToken = saved
+		mutex.Unlock()
+	})
+	mux := http.NewServeMux()
+	setupMetricsHandlers(mux)
+
+	tests := []struct {
+		name   string
+		token  string
+		remote string
+		auth   string
+		want   int
+	}{
+		{"local without token", "", "127.0.0.1:5000", "", http.StatusOK},
+		{"remote without token", "", "192.168.1.20:5000", "", http.StatusForbidden},
+		{"remote with token", "s3cret", "192.168.1.20:5000", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "192.168.1.20:5000", "Bearer nope", http.StatusUnauthorized},
+		{"missing token", "s3cret", "127.0.0.1:5000", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex.Lock()
+			metricsToken = tt.token
+			mutex.Unlock()
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.RemoteAddr = tt.remote
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("/metrics = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
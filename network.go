@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// NetworkAddress 本机的一个 IPv4 地址
+type NetworkAddress struct {
+	Interface string `json:"interface"`
+	IP        string `json:"ip"`
+	Private   bool   `json:"private"` // 局域网地址
+	Virtual   bool   `json:"virtual"` // 虚拟机、容器等虚拟网卡，手机通常无法访问
+}
+
+// virtualInterfacePrefixes 常见虚拟网卡名称前缀（小写）
+var virtualInterfacePrefixes = []string{
+	"docker", "br-", "veth", "virbr", "vboxnet", "virtualbox", "vmnet", "vmware",
+	"vethernet", "hyper-v", "utun", "tun", "tap", "zt", "tailscale", "wg",
+}
+
+// isVirtualInterface 按名称判断是否为虚拟网卡
+func isVirtualInterface(name string) bool {
+	n := strings.ToLower(name)
+	for _, p := range virtualInterfacePrefixes {
+		if strings.HasPrefix(n, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// listNetworkAddresses 列出所有已启用网卡的 IPv4 地址（不含回环地址），真实网卡的局域网地址排在前面
+func listNetworkAddresses() []NetworkAddress {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var preferred, others []NetworkAddress
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+			if ip == nil || ip.IsLoopback() || ip.To4() == nil {
+				continue
+			}
+			a := NetworkAddress{
+				Interface: iface.Name,
+				IP:        ip.To4().String(),
+				Virtual:   isVirtualInterface(iface.Name),
+			}
+			a.Private = isValidLocalIP(a.IP)
+			if a.Private && !a.Virtual {
+				preferred = append(preferred, a)
+			} else {
+				others = append(others, a)
+			}
+		}
+	}
+	return append(preferred, others...)
+}
+
+// localIPFor 本机用于回复 peer 的 IPv4 地址：与 peer 同一网段的地址，否则为自动检测或管理员选择的地址
+func localIPFor(peer net.IP) net.IP {
+	if peer != nil {
+		ifaces, _ := net.Interfaces()
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagUp == 0 {
+				continue
+			}
+			addrs, _ := iface.Addrs()
+			for _, addr := range addrs {
+				if n, ok := addr.(*net.IPNet); ok && n.IP.To4() != nil && n.Contains(peer) {
+					return n.IP.To4()
+				}
+			}
+		}
+	}
+	mutex.Lock()
+	adv := advertiseHost
+	mutex.Unlock()
+	if ip := net.ParseIP(adv); ip != nil && ip.To4() != nil {
+		return ip.To4()
+	}
+	return net.ParseIP(getLocalIP()).To4()
+}
+
+// validateAdvertiseHost 检查管理员填写的访问地址：IP 或主机名，不含协议和端口
+func validateAdvertiseHost(h string) error {
+	if h == "" || net.ParseIP(h) != nil {
+		return nil
+	}
+	for _, c := range h {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-') {
+			return fmt.Errorf("访问地址 %q 无效，只需填写 IP 或主机名，不含 http:// 和端口", h)
+		}
+	}
+	return nil
+}
+
+// listenPort 监听端口，服务运行时为实际监听的端口，调用方需持有 mutex
+func listenPort() string {
+	addr := effectiveListenAddrLocked()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return strings.TrimPrefix(addr, ":")
+	}
+	return port
+}
+
+// currentURLLocked 按当前设置计算访问地址：优先使用管理员选择的地址或主机名，
+// 否则使用监听地址或自动检测的本机 IP，调用方需持有 mutex
+func currentURLLocked() string {
+	if advertiseHost != "" {
+		return urlSchemeLocked() + "://" + net.JoinHostPort(advertiseHost, listenPort())
+	}
+	return serverURL(localIP())
+}
+
+// publicURLLocked 二维码和页面中使用的访问地址，服务运行时为启动时确定的地址，调用方需持有 mutex
+func publicURLLocked() string {
+	if baseURL != "" {
+		return baseURL
+	}
+	return currentURLLocked()
+}
+
+// publicURL 二维码和页面中使用的访问地址
+func publicURL() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return publicURLLocked()
+}
+
+// setAdvertiseHost 设置二维码中使用的地址（为空时自动检测），服务运行中立即生效，并保存到活动配置
+func setAdvertiseHost(h string) (string, error) {
+	h = strings.TrimSpace(h)
+	if err := validateAdvertiseHost(h); err != nil {
+		return "", err
+	}
+	mutex.Lock()
+	advertiseHost = h
+	if baseURL != "" {
+		baseURL = currentURLLocked()
+	}
+	u := publicURLLocked()
+	mutex.Unlock()
+	return u, saveCurrentEventConfig()
+}
+
+// setupNetworkHandlers 管理后台的访问地址设置
+func setupNetworkHandlers(mux *http.ServeMux) {
+	// GET 列出本机地址和当前设置，POST {"host": "...", "mdns_name": "..."} 修改（host 为空时自动检测）
+	mux.HandleFunc("/api/admin/network", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req struct {
+				Host     string  `json:"host"`
+				MDNSName *string `json:"mdns_name"` // 不填时不修改
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if req.MDNSName != nil {
+				if err := setMDNSName(*req.MDNSName); err != nil {
+					writeError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if _, err := setAdvertiseHost(req.Host); err != nil {
+				writeError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			detail := map[string]string{"host": req.Host}
+			if req.MDNSName != nil {
+				detail["mdns_name"] = *req.MDNSName
+			}
+			auditRequest(r, AuditNetwork, detail)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+			return
+		}
+		mutex.Lock()
+		host, name := advertiseHost, mdnsName
+		u := publicURLLocked()
+		mutex.Unlock()
+		writeJSON(w, map[string]interface{}{
+			"advertise_host": host,
+			"mdns_name":      name,
+			"url":            u,
+			"addresses":      listNetworkAddresses(),
+		})
+	}))
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestValidateAdvertiseHost(t *testing.T) {
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"", true},
+		{"192.168.1.20", true},
+		{"fe80::1", true},
+		{"quiz.local", true},
+		{"quiz-01", true},
+		{"http://quiz.local", false},
+		{"quiz.local:8080", false},
+		{"quiz local", false},
+		{"答题", false},
+	}
+	for _, tt := range tests {
+		if err := validateAdvertiseHost(tt.host); (err == nil) != tt.ok {
+			t.Errorf("validateAdvertiseHost(%q) error = %v, want ok %v", tt.host, err, tt.ok)
+		}
+	}
+}
+
+func TestIsVirtualInterface(t *testing.T) {
+	for name, want := range map[string]bool{
+		"eth0":                       false,
+		"wlan0":                      false,
+		"以太网":                        false,
+		"en0":                        false,
+		"docker0":                    true,
+		"br-3f2a":                    true,
+		"vEthernet (Default Switch)": true,
+		"VMware Network Adapter":     true,
+		"utun3":                      true,
+		"tailscale0":                 true,
+	} {
+		if got := isVirtualInterface(name); got != want {
+			t.Errorf("isVirtualInterface(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCurrentURLWithAdvertiseHost(t *testing.T) {
+	mutex.Lock()
+	savedHost, savedAddr, savedBound, savedTLS := advertiseHost, listenAddr, boundAddr, tlsEnabled
+	advertiseHost, listenAddr, boundAddr, tlsEnabled = "quiz.local", ":8080", "", false
+	got := currentURLLocked()
+	boundAddr, tlsEnabled = "[::]:9090", true
+	bound := currentURLLocked()
+	advertiseHost, listenAddr, boundAddr, tlsEnabled = savedHost, savedAddr, savedBound, savedTLS
+	mutex.Unlock()
+
+	if got != "http://quiz.local:8080" {
+		t.Errorf("currentURLLocked() = %q, want http://quiz.local:8080", got)
+	}
+	// 服务运行时使用实际监听的端口和协议
+	if bound != "http://quiz.local:9090" {
+		t.Errorf("currentURLLocked() while running = %q, want http://quiz.local:9090", bound)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import "strings"
+
+// 奖品类型
+const (
+	PrizeKindCode        = "code"        // 兑换码，从 Sheet2 中发放
+	PrizeKindItem        = "item"        // 实物奖品，按库存数量发放，没有兑换码
+	PrizeKindConsolation = "consolation" // 参与奖，未获得其它奖品时发放
+)
+
+// Award 一次答题最终发放的奖品
+type Award struct {
+	Level string `json:"prize_level"`
+	Kind  string `json:"prize_kind"`
+	Code  string `json:"code"`
+	Prize string `json:"prize"`
+}
+
+// itemIssued 各实物/参与奖等级已发放数量，调用方需持有 mutex
+var itemIssued = map[string]int{}
+
+// parsePrizeKind 解析 Excel 中的奖品类型，兼容中文写法，默认为兑换码
+func parsePrizeKind(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case PrizeKindItem, "实物", "实物奖品":
+		return PrizeKindItem
+	case PrizeKindConsolation, "参与奖", "安慰奖":
+		return PrizeKindConsolation
+	default:
+		return PrizeKindCode
+	}
+}
+
+// limitedStock 该等级是否按库存计数（兑换码看 Sheet2，实物看库存，参与奖库存为 0 表示不限量）
+func (l PrizeLevel) limitedStock() bool {
+	return l.Kind != PrizeKindConsolation || l.Stock > 0
+}
+
+// itemRemainingLocked 实物/参与奖剩余库存，调用方需持有 mutex
+func itemRemainingLocked(l PrizeLevel) int {
+	r := l.Stock - itemIssued[l.Level]
+	if r < 0 {
+		r = 0
+	}
+	return r
+}
+
+// assignPrizeByLevel 按等级分配奖品，调用方需持有 mutex
+func assignPrizeByLevel(l PrizeLevel) (Award, bool) {
+	switch l.Kind {
+	case PrizeKindItem, PrizeKindConsolation:
+		if l.limitedStock() && itemRemainingLocked(l) <= 0 {
+			return Award{}, false
+		}
+		itemIssued[l.Level]++
+		return Award{Level: l.Level, Kind: l.Kind, Prize: l.Prize}, true
+	default:
+		for i, prize := range prizeCodes {
+			if prize.Level == l.Level && !prize.Used {
+				// 标记为已使用并从可用列表中移除
+				prizeCodes[i].Used = true
+				return Award{Level: l.Level, Kind: PrizeKindCode, Code: prize.Code, Prize: l.Prize}, true
+			}
+		}
+	}
+	return Award{}, false
+}
+
+// releaseAwardLocked 答题结果未能保存时退回已分配的奖品（兑换码恢复为未使用，实物库存加回），调用方需持有 mutex
+func releaseAwardLocked(a Award) {
+	switch {
+	case a.Level == "":
+	case a.Code != "":
+		for i, c := range prizeCodes {
+			if c.Code == a.Code && c.Level == a.Level {
+				prizeCodes[i].Used = false
+				break
+			}
+		}
+	case itemIssued[a.Level] > 0:
+		itemIssued[a.Level]--
+	}
+	if onInventoryChange != nil {
+		onInventoryChange()
+	}
+}
+
+// awardPrizeLocked 根据得分百分比发放奖品，调用方需持有 mutex。
+// 按奖品等级从高到低尝试分配，某等级发完时按该等级的 Fallback 处理；
+// 未达到及格线或都没有分配到时发放参与奖。
+func awardPrizeLocked(percentage int) Award {
+	if percentage >= passScore {
+	levels:
+		for _, l := range prizeLevels {
+			if l.Kind == PrizeKindConsolation || percentage < l.Score {
+				continue
+			}
+			if a, ok := assignPrizeByLevel(l); ok {
+				checkInventoryLocked(l.Level)
+				return a
+			}
+			switch l.Fallback {
+			case FallbackNone:
+				return Award{}
+			case FallbackConsolation:
+				break levels
+			}
+		}
+	}
+	for _, l := range prizeLevels {
+		if l.Kind != PrizeKindConsolation {
+			continue
+		}
+		if a, ok := assignPrizeByLevel(l); ok {
+			checkInventoryLocked(l.Level)
+			return a
+		}
+	}
+	return Award{}
+}
+
+// restoreIssuedItems 根据答题记录恢复今天已发放的实物/参与奖数量，避免重启后超发，调用方需持有 mutex
+func restoreIssuedItems(recs []ResultRecord) map[string]int {
+	return prizesIssuedToday(recs)
+}
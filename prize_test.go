@@ -0,0 +1,129 @@
+package main
+
+import "testing"
+
+// usePrizes 使用给定的奖品规则、兑换码和及格线，测试结束后恢复
+func usePrizes(t *testing.T, levels []PrizeLevel, codes []PrizeCode, pass int) {
+	t.Helper()
+	levels, err := normalizePrizeLevels(levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	savedLevels, savedCodes, savedIssued, savedPass := prizeLevels, prizeCodes, itemIssued, passScore
+	prizeLevels, prizeCodes, itemIssued, passScore = levels, codes, map[string]int{}, pass
+	resetInventoryAlerts()
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		prizeLevels, prizeCodes, itemIssued, passScore = savedLevels, savedCodes, savedIssued, savedPass
+		resetInventoryAlerts()
+		mutex.Unlock()
+	})
+}
+
+func TestAwardPrizeLocked(t *testing.T) {
+	codes := func() []PrizeCode {
+		return []PrizeCode{{Code: "A1", Level: "一等奖"}, {Code: "B1", Level: "二等奖"}}
+	}
+	tests := []struct {
+		name   string
+		levels []PrizeLevel
+		pass   int
+		scores []int    // 依次提交的得分百分比
+		want   []string // 每次获得的等级，空表示没有奖品
+	}{
+		{
+			"by score",
+			[]PrizeLevel{{Level: "一等奖", Score: 100}, {Level: "二等奖", Score: 80}},
+			0, []int{100, 85, 70}, []string{"一等奖", "二等奖", ""},
+		},
+		{
+			"downgrade when exhausted",
+			[]PrizeLevel{{Level: "一等奖", Score: 100}, {Level: "二等奖", Score: 80}},
+			0, []int{100, 100, 100}, []string{"一等奖", "二等奖", ""},
+		},
+		{
+			"fallback none",
+			[]PrizeLevel{{Level: "一等奖", Score: 100, Fallback: FallbackNone}, {Level: "二等奖", Score: 80}, {Level: "参与奖", Kind: PrizeKindConsolation}},
+			0, []int{100, 100}, []string{"一等奖", ""},
+		},
+		{
+			"fallback consolation",
+			[]PrizeLevel{{Level: "一等奖", Score: 100, Fallback: FallbackConsolation}, {Level: "二等奖", Score: 80}, {Level: "参与奖", Kind: PrizeKindConsolation}},
+			0, []int{100, 100}, []string{"一等奖", "参与奖"},
+		},
+		{
+			"below pass score",
+			[]PrizeLevel{{Level: "二等奖", Score: 50}, {Level: "参与奖", Kind: PrizeKindConsolation}},
+			60, []int{55, 60}, []string{"参与奖", "二等奖"},
+		},
+		{
+			"item stock",
+			[]PrizeLevel{{Level: "纪念品", Score: 50, Kind: PrizeKindItem, Stock: 2}},
+			0, []int{90, 90, 90}, []string{"纪念品", "纪念品", ""},
+		},
+		{
+			"limited consolation",
+			[]PrizeLevel{{Level: "参与奖", Kind: PrizeKindConsolation, Stock: 1}},
+			0, []int{10, 10}, []string{"参与奖", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePrizes(t, tt.levels, codes(), tt.pass)
+			mutex.Lock()
+			defer mutex.Unlock()
+			for i, score := range tt.scores {
+				if got := awardPrizeLocked(score); got.Level != tt.want[i] {
+					t.Fatalf("submission %d (%d%%): level = %q, want %q", i, score, got.Level, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseAwardLocked(t *testing.T) {
+	usePrizes(t, []PrizeLevel{
+		{Level: "一等奖", Score: 100},
+		{Level: "纪念品", Score: 50, Kind: PrizeKindItem, Stock: 1},
+	}, []PrizeCode{{Code: "A1", Level: "一等奖"}}, 0)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	code := awardPrizeLocked(100)
+	item := awardPrizeLocked(60)
+	if code.Code != "A1" || item.Level != "纪念品" {
+		t.Fatalf("awards = %+v, %+v", code, item)
+	}
+	if a := awardPrizeLocked(100); a.Level != "" {
+		t.Fatalf("award after stock exhausted = %+v", a)
+	}
+	releaseAwardLocked(code)
+	releaseAwardLocked(item)
+	releaseAwardLocked(Award{})
+	if a := awardPrizeLocked(100); a.Code != "A1" {
+		t.Fatalf("code not released: %+v", a)
+	}
+	if a := awardPrizeLocked(60); a.Level != "纪念品" {
+		t.Fatalf("item not released: %+v", a)
+	}
+}
+
+func TestParsePrizeKind(t *testing.T) {
+	tests := map[string]string{
+		"":     PrizeKindCode,
+		"code": PrizeKindCode,
+		"Item": PrizeKindItem,
+		" 实物 ": PrizeKindItem,
+		"实物奖品": PrizeKindItem,
+		"参与奖":  PrizeKindConsolation,
+		"安慰奖":  PrizeKindConsolation,
+		"其它":   PrizeKindCode,
+	}
+	for in, want := range tests {
+		if got := parsePrizeKind(in); got != want {
+			t.Errorf("parsePrizeKind(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/base64"
+
 	"github.com/skip2/go-qrcode"
 )
 
@@ -12,3 +13,15 @@ func generateQRCodeBase64(url string) (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(pngBytes), nil
 }
+
+//func generateQRCodeBase64(url string) (string, error) {
+//	pngBytes, err := qrcode.Encode(url, qrcode.Medium, 512)
+//	if err != nil {
+//		return "", err
+//	}
+//	return base64.StdEncoding.EncodeToString(pngBytes), nil
+//}
+
+func generateQRCodeBytes(url string) ([]byte, error) {
+	return qrcode.Encode(url, qrcode.Medium, 512)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxBodyKB 公开接口请求体的默认上限（KB）
+	defaultMaxBodyKB = 64
+	// defaultBanAfter 默认在 banWindow 内被限流多少次后临时封禁
+	defaultBanAfter = 30
+	// defaultBanMinutes 默认封禁时长（分钟）
+	defaultBanMinutes = 15
+	// banWindow 统计被限流次数的时间窗口
+	banWindow = 10 * time.Minute
+	// rateLimitIdle 超过该时间没有请求的计数会被清理
+	rateLimitIdle = 10 * time.Minute
+)
+
+// RateRule 某个接口对每个 IP 的令牌桶限制
+type RateRule struct {
+	PerMinute float64 `json:"per_minute" toml:"per_minute"` // 每分钟补充的请求数，0 表示不限制
+	Burst     int     `json:"burst" toml:"burst"`           // 允许的突发请求数
+}
+
+// RateLimitConfig 公开接口的访问限制，留空使用默认值
+type RateLimitConfig struct {
+	Endpoints  map[string]RateRule `json:"endpoints,omitempty" toml:"endpoints,omitempty"`     // 按路径覆盖默认限制，如 "/api/submit"
+	MaxBodyKB  int                 `json:"max_body_kb,omitempty" toml:"max_body_kb,omitempty"` // 公开接口请求体上限（KB）
+	BanAfter   int                 `json:"ban_after,omitempty" toml:"ban_after,omitempty"`     // 10 分钟内被限流多少次后临时封禁
+	BanMinutes int                 `json:"ban_minutes,omitempty" toml:"ban_minutes,omitempty"` // 封禁时长（分钟）
+}
+
+// defaultRateRules 默认限制：查询是否答过题和开始答题每分钟 10 次，提交答卷每分钟 6 次，
+// 下载凭证（每次都要生成图片）每分钟 6 次。
+// 同一出口 IP 后面可能有多台手机（如运营商 NAT），突发数不宜过小
+var defaultRateRules = map[string]RateRule{
+	"/api/check-user":  {PerMinute: 10, Burst: 10},
+	"/api/questions":   {PerMinute: 10, Burst: 10},
+	"/api/submit":      {PerMinute: 6, Burst: 6},
+	"/api/certificate": {PerMinute: 6, Burst: 6},
+}
+
+// tokenBucket 某个 IP 在某个接口上的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// abuseRecord 某个 IP 被限流的次数和封禁状态
+type abuseRecord struct {
+	IP          string    `json:"ip"`
+	Hits        int       `json:"hits"`         // 窗口期内被限流次数
+	First       time.Time `json:"first"`        // 窗口期开始时间
+	BannedUntil time.Time `json:"banned_until"` // 封禁到期时间，未封禁时为零值
+	Path        string    `json:"path"`         // 最近一次被限流的接口
+}
+
+var (
+	// rateMu 保护以下访问限制状态。与 mutex 分开，每个公开请求（包括静态页面）都要取得，
+	// 不能排在写入结果文件的提交请求后面；持有 rateMu 时不会再去取 mutex
+	rateMu sync.Mutex
+	// rateLimit 访问限制设置，调用方需持有 rateMu
+	rateLimit RateLimitConfig
+	// rateBuckets 令牌桶，按 "IP 路径" 索引，调用方需持有 rateMu
+	rateBuckets = map[string]*tokenBucket{}
+	// abuseRecords 被限流和封禁的 IP，调用方需持有 rateMu
+	abuseRecords = map[string]*abuseRecord{}
+	// rateLimitPruned 上次清理过期计数的时间，调用方需持有 rateMu
+	rateLimitPruned time.Time
+)
+
+// validateRateLimit 检查访问限制设置
+func validateRateLimit(c RateLimitConfig) error {
+	for path, rule := range c.Endpoints {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("限流接口 %q 应以 / 开头", path)
+		}
+		if rule.PerMinute < 0 || rule.Burst < 0 {
+			return fmt.Errorf("接口 %s 的限流设置不能为负数", path)
+		}
+	}
+	if c.MaxBodyKB < 0 || c.BanAfter < 0 || c.BanMinutes < 0 {
+		return fmt.Errorf("访问限制设置不能为负数")
+	}
+	return nil
+}
+
+// rateRuleLocked 接口的限制，未设置时使用默认值，调用方需持有 rateMu
+func rateRuleLocked(path string) (RateRule, bool) {
+	if rule, ok := rateLimit.Endpoints[path]; ok {
+		return rule, rule.PerMinute > 0
+	}
+	rule, ok := defaultRateRules[path]
+	return rule, ok
+}
+
+// maxBodyBytesLocked 公开接口请求体上限，调用方需持有 rateMu
+func maxBodyBytesLocked() int64 {
+	kb := rateLimit.MaxBodyKB
+	if kb == 0 {
+		kb = defaultMaxBodyKB
+	}
+	return int64(kb) << 10
+}
+
+// bannedUntilLocked IP 的封禁到期时间，未封禁时返回零值，调用方需持有 rateMu
+func bannedUntilLocked(ip string, now time.Time) time.Time {
+	if a, ok := abuseRecords[ip]; ok && now.Before(a.BannedUntil) {
+		return a.BannedUntil
+	}
+	return time.Time{}
+}
+
+// allowRequestLocked 从令牌桶取一个令牌，没有令牌时记录一次限流，次数过多时封禁该 IP，调用方需持有 rateMu
+func allowRequestLocked(ip, path string, rule RateRule, now time.Time) bool {
+	pruneRateLimitLocked(now)
+	burst := float64(rule.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	key := ip + " " + path
+	b, ok := rateBuckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		rateBuckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Minutes()*rule.PerMinute)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+
+	// 窗口期已过或封禁已到期时重新计数，否则解封后的第一次限流就会再次封禁
+	a, ok := abuseRecords[ip]
+	if !ok || now.Sub(a.First) > banWindow || (!a.BannedUntil.IsZero() && !now.Before(a.BannedUntil)) {
+		a = &abuseRecord{IP: ip, First: now, BannedUntil: bannedUntilLocked(ip, now)}
+		abuseRecords[ip] = a
+	}
+	a.Hits++
+	a.Path = path
+	banAfter, banMinutes := rateLimit.BanAfter, rateLimit.BanMinutes
+	if banAfter == 0 {
+		banAfter = defaultBanAfter
+	}
+	if banMinutes == 0 {
+		banMinutes = defaultBanMinutes
+	}
+	if a.Hits >= banAfter {
+		a.BannedUntil = now.Add(time.Duration(banMinutes) * time.Minute)
+	}
+	return false
+}
+
+// pruneRateLimitLocked 每分钟清理一次已恢复满额的令牌桶和过期的封禁记录，调用方需持有 rateMu
+func pruneRateLimitLocked(now time.Time) {
+	if now.Sub(rateLimitPruned) < time.Minute {
+		return
+	}
+	rateLimitPruned = now
+	for k, b := range rateBuckets {
+		if now.Sub(b.last) > rateLimitIdle {
+			delete(rateBuckets, k)
+		}
+	}
+	for ip, a := range abuseRecords {
+		if now.Sub(a.First) > banWindow && !now.Before(a.BannedUntil) {
+			delete(abuseRecords, ip)
+		}
+	}
+}
+
+// isPublicPath 参与者使用的页面和接口（管理后台有登录和单独的上传限制）
+func isPublicPath(path string) bool {
+	return path != "/admin" && !strings.HasPrefix(path, "/admin/") && !strings.HasPrefix(path, "/api/admin/")
+}
+
+// rejectTooMany 返回 429 和重试时间
+func rejectTooMany(w http.ResponseWriter, msg string, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	writeError(w, msg, http.StatusTooManyRequests)
+}
+
+// rateLimitHandler 公开接口的访问限制：被封禁的 IP 不能访问，限制请求体大小，按 IP 和接口限流
+func rateLimitHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip, now := clientIP(r), time.Now()
+		rateMu.Lock()
+		until := bannedUntilLocked(ip, now)
+		maxBody := maxBodyBytesLocked()
+		rule, limited := rateRuleLocked(r.URL.Path)
+		allowed := until.IsZero() && (!limited || allowRequestLocked(ip, r.URL.Path, rule, now))
+		if !allowed && until.IsZero() {
+			until = bannedUntilLocked(ip, now)
+		}
+		rateMu.Unlock()
+
+		switch {
+		case !until.IsZero():
+			rejectTooMany(w, "访问过于频繁，已暂时限制访问，请稍后再试", until.Sub(now))
+			return
+		case !allowed:
+			rejectTooMany(w, "访问过于频繁，请稍后再试", time.Duration(float64(time.Minute)/rule.PerMinute))
+			return
+		}
+		if r.ContentLength > maxBody {
+			writeError(w, "请求内容过大", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listAbuseRecords 当前被限流或封禁的 IP，封禁中的排在前面
+func listAbuseRecords() []abuseRecord {
+	now := time.Now()
+	rateMu.Lock()
+	list := make([]abuseRecord, 0, len(abuseRecords))
+	for _, a := range abuseRecords {
+		if now.Sub(a.First) <= banWindow || now.Before(a.BannedUntil) {
+			list = append(list, *a)
+		}
+	}
+	rateMu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		bi, bj := now.Before(list[i].BannedUntil), now.Before(list[j].BannedUntil)
+		if bi != bj {
+			return bi
+		}
+		if list[i].Hits != list[j].Hits {
+			return list[i].Hits > list[j].Hits
+		}
+		return list[i].IP < list[j].IP
+	})
+	return list
+}
+
+// unbanIP 解除 IP 的封禁并清空其计数
+func unbanIP(ip string) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	delete(abuseRecords, ip)
+	prefix := ip + " "
+	for k := range rateBuckets {
+		if strings.HasPrefix(k, prefix) {
+			delete(rateBuckets, k)
+		}
+	}
+}
+
+// setupRateLimitHandlers 管理后台查看和解除封禁
+func setupRateLimitHandlers(mux *http.ServeMux) {
+	// GET 列出被限流和封禁的 IP，POST {"ip": "..."} 解除封禁
+	mux.HandleFunc("/api/admin/bans", requireRole(rolesOrganizer, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req struct {
+				IP string `json:"ip"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IP == "" {
+				writeError(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			unbanIP(req.IP)
+			auditRequest(r, AuditUnban, map[string]string{"ip": req.IP})
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+			return
+		}
+		writeJSON(w, listAbuseRecords())
+	}))
+}
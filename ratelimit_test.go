@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetRateLimit 清空令牌桶和封禁记录，使用给定的设置
+func resetRateLimit(t *testing.T, c RateLimitConfig) {
+	t.Helper()
+	rateMu.Lock()
+	rateLimit = c
+	rateBuckets = map[string]*tokenBucket{}
+	abuseRecords = map[string]*abuseRecord{}
+	rateLimitPruned = time.Time{}
+	rateMu.Unlock()
+	t.Cleanup(func() {
+		rateMu.Lock()
+		rateLimit = RateLimitConfig{}
+		rateBuckets = map[string]*tokenBucket{}
+		abuseRecords = map[string]*abuseRecord{}
+		rateMu.Unlock()
+	})
+}
+
+func TestAllowRequestLocked(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	rule := RateRule{PerMinute: 6, Burst: 3}
+	tests := []struct {
+		name  string
+		steps []time.Duration // 每次请求距开始的时间
+		want  []bool
+	}{
+		{"burst then limited", []time.Duration{0, 0, 0, 0}, []bool{true, true, true, false}},
+		{"refill after ten seconds", []time.Duration{0, 0, 0, 0, 10 * time.Second}, []bool{true, true, true, false, true}},
+		{"refill capped at burst", []time.Duration{0, time.Hour, time.Hour, time.Hour, time.Hour}, []bool{true, true, true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRateLimit(t, RateLimitConfig{})
+			rateMu.Lock()
+			defer rateMu.Unlock()
+			for i, d := range tt.steps {
+				if got := allowRequestLocked("10.0.0.1", "/api/submit", rule, start.Add(d)); got != tt.want[i] {
+					t.Fatalf("request %d at +%v: allowed = %v, want %v", i, d, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitBan(t *testing.T) {
+	resetRateLimit(t, RateLimitConfig{BanAfter: 3, BanMinutes: 5})
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	rule := RateRule{PerMinute: 1, Burst: 1}
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	allowRequestLocked("10.0.0.1", "/api/questions", rule, now)
+	for i := 0; i < 3; i++ {
+		allowRequestLocked("10.0.0.1", "/api/questions", rule, now)
+	}
+	if got := bannedUntilLocked("10.0.0.1", now); !got.Equal(now.Add(5 * time.Minute)) {
+		t.Fatalf("bannedUntil = %v, want %v", got, now.Add(5*time.Minute))
+	}
+	if got := bannedUntilLocked("10.0.0.2", now); !got.IsZero() {
+		t.Fatalf("other IP banned until %v", got)
+	}
+	later := now.Add(6 * time.Minute)
+	if got := bannedUntilLocked("10.0.0.1", later); !got.IsZero() {
+		t.Fatalf("ban not expired: %v", got)
+	}
+
+	// 解封后仍在统计窗口内，再被限流一次不应立即再次封禁
+	allowRequestLocked("10.0.0.1", "/api/questions", rule, later)
+	allowRequestLocked("10.0.0.1", "/api/questions", rule, later)
+	if got := bannedUntilLocked("10.0.0.1", later); !got.IsZero() {
+		t.Fatalf("banned again right after the ban expired, until %v", got)
+	}
+	if hits := abuseRecords["10.0.0.1"].Hits; hits != 1 {
+		t.Fatalf("hits after ban expired = %d, want 1", hits)
+	}
+}
+
+func TestUnbanIP(t *testing.T) {
+	resetRateLimit(t, RateLimitConfig{BanAfter: 1})
+	now := time.Now()
+	rule := RateRule{PerMinute: 1, Burst: 1}
+	rateMu.Lock()
+	allowRequestLocked("10.0.0.1", "/api/submit", rule, now)
+	allowRequestLocked("10.0.0.1", "/api/submit", rule, now)
+	allowRequestLocked("10.0.0.2", "/api/submit", rule, now)
+	rateMu.Unlock()
+	if list := listAbuseRecords(); len(list) != 1 || list[0].IP != "10.0.0.1" || list[0].BannedUntil.IsZero() {
+		t.Fatalf("listAbuseRecords() = %+v", list)
+	}
+
+	unbanIP("10.0.0.1")
+	if list := listAbuseRecords(); len(list) != 0 {
+		t.Fatalf("listAbuseRecords() after unban = %+v", list)
+	}
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	if !allowRequestLocked("10.0.0.1", "/api/submit", rule, now) {
+		t.Fatal("bucket not reset by unbanIP")
+	}
+	if allowRequestLocked("10.0.0.2", "/api/submit", rule, now) {
+		t.Fatal("unbanIP reset another IP's bucket")
+	}
+}
+
+func TestRateRuleLocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints map[string]RateRule
+		path      string
+		want      RateRule
+		limited   bool
+	}{
+		{"default rule", nil, "/api/submit", defaultRateRules["/api/submit"], true},
+		{"no rule", nil, "/identity.html", RateRule{}, false},
+		{"override", map[string]RateRule{"/api/submit": {PerMinute: 2, Burst: 1}}, "/api/submit", RateRule{PerMinute: 2, Burst: 1}, true},
+		{"override disables", map[string]RateRule{"/api/submit": {}}, "/api/submit", RateRule{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRateLimit(t, RateLimitConfig{Endpoints: tt.endpoints})
+			rateMu.Lock()
+			got, limited := rateRuleLocked(tt.path)
+			rateMu.Unlock()
+			if got != tt.want || limited != tt.limited {
+				t.Fatalf("rateRuleLocked(%q) = %+v, %v, want %+v, %v", tt.path, got, limited, tt.want, tt.limited)
+			}
+		})
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	resetRateLimit(t, RateLimitConfig{
+		Endpoints: map[string]RateRule{"/api/check-user": {PerMinute: 1, Burst: 2}},
+		MaxBodyKB: 1,
+	})
+	h := rateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.9:5000"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"first", "/api/check-user", "{}", http.StatusNoContent},
+		{"second", "/api/check-user", "{}", http.StatusNoContent},
+		{"over burst", "/api/check-user", "{}", http.StatusTooManyRequests},
+		{"other endpoint unaffected", "/api/result", "{}", http.StatusNoContent},
+		{"admin not limited", "/api/admin/login", strings.Repeat("x", 4096), http.StatusNoContent},
+		{"body too large", "/api/result", strings.Repeat("x", 2048), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w := do(tt.path, tt.body)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s: missing Retry-After", tt.name)
+		}
+	}
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		c    RateLimitConfig
+		ok   bool
+	}{
+		{"empty", RateLimitConfig{}, true},
+		{"valid", RateLimitConfig{Endpoints: map[string]RateRule{"/api/submit": {PerMinute: 3, Burst: 3}}, BanAfter: 5}, true},
+		{"relative path", RateLimitConfig{Endpoints: map[string]RateRule{"api/submit": {PerMinute: 3}}}, false},
+		{"negative rate", RateLimitConfig{Endpoints: map[string]RateRule{"/api/submit": {PerMinute: -1}}}, false},
+		{"negative ban", RateLimitConfig{BanMinutes: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRateLimit(tt.c); (err == nil) != tt.ok {
+				t.Fatalf("validateRateLimit() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPageSize 记录查询默认每页条数
+const defaultPageSize = 20
+
+// RecordFilter 答题记录查询条件，零值表示不限
+type RecordFilter struct {
+	From        time.Time // 起始日期（含）
+	To          time.Time // 结束日期（含当天）
+	MinScore    int       // 最低得分百分比
+	MaxScore    int       // 最高得分百分比，0 表示不限
+	Level       string    // 奖品等级，"-" 表示未获奖
+	PhoneSuffix string    // 脱敏手机号的后几位
+}
+
+// RecordSummary 记录列表中的一行，不包含姓名手机号等哈希信息
+type RecordSummary struct {
+	AttemptID  string    `json:"attempt_id"`
+	Time       time.Time `json:"time"`
+	MaskName   string    `json:"mask_name"`
+	MaskPhone  string    `json:"mask_phone"`
+	Score      int       `json:"score"`
+	Total      int       `json:"total"`
+	Percentage int       `json:"percentage"`
+	Level      string    `json:"prize_level"`
+	Prize      string    `json:"prize"`
+	Code       string    `json:"code"`
+}
+
+// RecordPage 一页查询结果
+type RecordPage struct {
+	Total   int             `json:"total"`
+	Page    int             `json:"page"`
+	Size    int             `json:"size"`
+	Records []RecordSummary `json:"records"`
+}
+
+// recordPercentage 得分百分比
+func recordPercentage(r ResultRecord) int {
+	if r.Total <= 0 {
+		return 0
+	}
+	return r.Score * 100 / r.Total
+}
+
+// match 记录是否符合查询条件
+func (f RecordFilter) match(r ResultRecord) bool {
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.Time.Before(f.To.AddDate(0, 0, 1)) {
+		return false
+	}
+	p := recordPercentage(r)
+	if p < f.MinScore || (f.MaxScore > 0 && p > f.MaxScore) {
+		return false
+	}
+	switch f.Level {
+	case "":
+	case "-":
+		if r.Award.Level != "" {
+			return false
+		}
+	default:
+		if r.Award.Level != f.Level {
+			return false
+		}
+	}
+	if f.PhoneSuffix != "" && !strings.HasSuffix(r.MaskPhone, f.PhoneSuffix) {
+		return false
+	}
+	return true
+}
+
+// summarizeRecord 记录列表中显示的内容
+func summarizeRecord(r ResultRecord) RecordSummary {
+	return RecordSummary{
+		AttemptID:  r.AttemptID,
+		Time:       r.Time,
+		MaskName:   r.MaskName,
+		MaskPhone:  r.MaskPhone,
+		Score:      r.Score,
+		Total:      r.Total,
+		Percentage: recordPercentage(r),
+		Level:      r.Award.Level,
+		Prize:      r.Award.Prize,
+		Code:       r.Award.Code,
+	}
+}
+
+// QueryRecords 按条件查询答题记录，最新的在前，page 从 1 开始
+func QueryRecords(f RecordFilter, page, size int) (RecordPage, error) {
+	if size <= 0 || size > 200 {
+		size = defaultPageSize
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	mutex.Lock()
+	recs, err := recordsLocked()
+	mutex.Unlock()
+	if err != nil {
+		return RecordPage{}, err
+	}
+
+	out := RecordPage{Page: page, Size: size, Records: []RecordSummary{}}
+	skip := (page - 1) * size
+	for i := len(recs) - 1; i >= 0; i-- {
+		if !f.match(recs[i]) {
+			continue
+		}
+		if out.Total >= skip && len(out.Records) < size {
+			out.Records = append(out.Records, summarizeRecord(recs[i]))
+		}
+		out.Total++
+	}
+	return out, nil
+}
+
+// parseRecordFilter 解析查询参数：from、to（YYYY-MM-DD，按活动时区）、min、max、level、phone
+func parseRecordFilter(q map[string][]string) (RecordFilter, error) {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	mutex.Lock()
+	loc := eventLocation
+	mutex.Unlock()
+
+	var f RecordFilter
+	var err error
+	if s := get("from"); s != "" {
+		if f.From, err = time.ParseInLocation("2006-01-02", s, loc); err != nil {
+			return f, fmt.Errorf("起始日期格式错误")
+		}
+	}
+	if s := get("to"); s != "" {
+		if f.To, err = time.ParseInLocation("2006-01-02", s, loc); err != nil {
+			return f, fmt.Errorf("结束日期格式错误")
+		}
+	}
+	if s := get("min"); s != "" {
+		if f.MinScore, err = strconv.Atoi(s); err != nil {
+			return f, fmt.Errorf("最低分格式错误")
+		}
+	}
+	if s := get("max"); s != "" {
+		if f.MaxScore, err = strconv.Atoi(s); err != nil {
+			return f, fmt.Errorf("最高分格式错误")
+		}
+	}
+	f.Level = get("level")
+	f.PhoneSuffix = get("phone")
+	return f, nil
+}
+
+// recordDetail 单次答题的完整信息（含每题作答详情），不包含姓名手机号等哈希信息
+func recordDetail(id string) (map[string]interface{}, error) {
+	mutex.Lock()
+	rec, ok, err := lookupAttemptLocked(id)
+	qs := make([]Question, len(questions))
+	copy(qs, questions)
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errAttemptNotFound
+	}
+	resp := attemptResponse(rec)
+	resp["mask_phone"] = rec.MaskPhone
+	resp["mask_idCard"] = rec.MaskIdCard
+	resp["answers"] = recordAnswers(qs, parseDetail(rec))
+	if red, done, err := redemptionOf(rec.AttemptID); err == nil && done {
+		resp["redemption"] = red
+	}
+	return resp, nil
+}
+
+// RecordAnswer 答题详情中的一道题
+type RecordAnswer struct {
+	ID      string   `json:"id"`
+	Prompt  string   `json:"question"` // 当前题库中已没有该题时为空
+	Given   []string `json:"given"`
+	Correct bool     `json:"correct"`
+	Seconds float64  `json:"seconds"`
+}
+
+// recordAnswers 按当前题库顺序排列每题作答，题库中已不存在的题目排在最后
+func recordAnswers(qs []Question, detail map[string]detailEntry) []RecordAnswer {
+	out := make([]RecordAnswer, 0, len(detail))
+	seen := map[string]bool{}
+	for _, q := range qs {
+		if e, ok := detail[q.ID]; ok {
+			out = append(out, RecordAnswer{ID: q.ID, Prompt: q.Prompt, Given: e.Given, Correct: e.Correct, Seconds: e.Seconds})
+			seen[q.ID] = true
+		}
+	}
+	rest := []string{}
+	for id := range detail {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	for _, id := range rest {
+		e := detail[id]
+		out = append(out, RecordAnswer{ID: id, Given: e.Given, Correct: e.Correct, Seconds: e.Seconds})
+	}
+	return out
+}
+
+// setupRecordHandlers 答题记录查询接口
+func setupRecordHandlers(mux *http.ServeMux) {
+	// 分页查询：page、size 以及 parseRecordFilter 支持的条件。记录中有参与者信息和兑换码，查看者不能访问
+	mux.HandleFunc("/api/admin/records", requireRole(rolesRedeem, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		q := r.URL.Query()
+		f, err := parseRecordFilter(q)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, _ := strconv.Atoi(q.Get("page"))
+		size, _ := strconv.Atoi(q.Get("size"))
+		res, err := QueryRecords(f, page, size)
+		if err != nil {
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, res)
+	}))
+
+	// 单次答题详情
+	mux.HandleFunc("/api/admin/record", requireRole(rolesRedeem, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			// 旧版结果文件中的记录没有答题编号，空编号会匹配到这些记录
+			writeError(w, "缺少答题编号", http.StatusBadRequest)
+			return
+		}
+		d, err := recordDetail(id)
+		if err == errAttemptNotFound {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, d)
+	}))
+}
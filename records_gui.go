@@ -0,0 +1,164 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showRecordsWindow 答题记录浏览：按日期、得分、奖品等级和手机尾号筛选，分页显示，选中一行查看详情
+func showRecordsWindow(a fyne.App) {
+	win := a.NewWindow("答题记录")
+	win.Resize(fyne.NewSize(760, 620))
+
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("YYYY-MM-DD")
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("YYYY-MM-DD")
+	minEntry := widget.NewEntry()
+	minEntry.SetPlaceHolder("0")
+	maxEntry := widget.NewEntry()
+	maxEntry.SetPlaceHolder("100")
+	levelEntry := widget.NewEntry()
+	levelEntry.SetPlaceHolder("全部，- 为未获奖")
+	phoneEntry := widget.NewEntry()
+	phoneEntry.SetPlaceHolder("手机尾号")
+
+	pageInfo := widget.NewLabel("")
+	var current RecordPage
+	page := 1
+
+	list := widget.NewList(
+		func() int { return len(current.Records) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			r := current.Records[i]
+			level := r.Level
+			if level == "" {
+				level = "-"
+			}
+			o.(*widget.Label).SetText(fmt.Sprintf("%s   %s   %s   %d/%d (%d%%)   %s",
+				r.Time.In(eventNow().Location()).Format("2006-01-02 15:04:05"),
+				r.MaskName, r.MaskPhone, r.Score, r.Total, r.Percentage, level))
+		},
+	)
+
+	load := func() {
+		f, err := parseRecordFilter(url.Values{
+			"from":  {fromEntry.Text},
+			"to":    {toEntry.Text},
+			"min":   {minEntry.Text},
+			"max":   {maxEntry.Text},
+			"level": {levelEntry.Text},
+			"phone": {phoneEntry.Text},
+		})
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		res, err := QueryRecords(f, page, defaultPageSize)
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		current = res
+		pages := (res.Total + res.Size - 1) / res.Size
+		if pages < 1 {
+			pages = 1
+		}
+		pageInfo.SetText(fmt.Sprintf("第 %d / %d 页，共 %d 条", res.Page, pages, res.Total))
+		list.UnselectAll()
+		list.Refresh()
+	}
+
+	list.OnSelected = func(i widget.ListItemID) {
+		if i >= len(current.Records) {
+			return
+		}
+		d, err := recordDetail(current.Records[i].AttemptID)
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		text := widget.NewLabel(formatRecordDetail(d))
+		text.Wrapping = fyne.TextWrapWord
+		scroll := container.NewVScroll(text)
+		scroll.SetMinSize(fyne.NewSize(520, 380))
+		dialog.ShowCustom("答题详情", "关闭", scroll, win)
+	}
+
+	btnSearch := widget.NewButton("查询", func() {
+		page = 1
+		load()
+	})
+	btnPrev := widget.NewButton("上一页", func() {
+		if page > 1 {
+			page--
+			load()
+		}
+	})
+	btnNext := widget.NewButton("下一页", func() {
+		if page*current.Size < current.Total {
+			page++
+			load()
+		}
+	})
+
+	filters := widget.NewForm(
+		widget.NewFormItem("日期", container.NewGridWithColumns(2, fromEntry, toEntry)),
+		widget.NewFormItem("得分(%)", container.NewGridWithColumns(2, minEntry, maxEntry)),
+		widget.NewFormItem("奖品等级", levelEntry),
+		widget.NewFormItem("手机尾号", phoneEntry),
+	)
+	top := container.NewVBox(
+		widget.NewLabel("结果文件: "+resultPath()),
+		filters,
+		container.NewHBox(btnSearch, btnPrev, pageInfo, btnNext),
+	)
+	win.SetContent(container.NewBorder(top, nil, nil, nil, list))
+	load()
+	win.Show()
+}
+
+// formatRecordDetail 答题详情的文本形式，每道题显示题目、作答、是否正确和用时
+func formatRecordDetail(d map[string]interface{}) string {
+	lines := []string{
+		fmt.Sprintf("答题编号: %v", d["attempt_id"]),
+		fmt.Sprintf("姓名: %v  手机: %v  身份证: %v", d["mask_name"], d["mask_phone"], d["mask_idCard"]),
+		fmt.Sprintf("得分: %v / %v (%v%%)", d["score"], d["total"], d["percentage"]),
+		fmt.Sprintf("奖品: %v %v %v", d["prize_level"], d["prize"], d["code"]),
+	}
+	if red, ok := d["redemption"].(Redemption); ok {
+		lines = append(lines, fmt.Sprintf("已于 %s 由 %s 核销", red.Time.In(eventNow().Location()).Format("2006-01-02 15:04:05"), red.By))
+	}
+	lines = append(lines, "")
+
+	answers, _ := d["answers"].([]RecordAnswer)
+	for _, e := range answers {
+		mark := "✗"
+		if e.Correct {
+			mark = "✓"
+		}
+		prompt := e.Prompt
+		if prompt == "" {
+			prompt = e.ID
+		}
+		given := strings.Join(e.Given, "、")
+		if given == "" {
+			given = "未作答"
+		}
+		line := fmt.Sprintf("%s %s\n    作答: %s", mark, prompt, given)
+		if e.Seconds > 0 {
+			line += fmt.Sprintf("，用时 %.0f 秒", e.Seconds)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRecordFilter(t *testing.T) {
+	mutex.Lock()
+	loc := eventLocation
+	mutex.Unlock()
+	tests := []struct {
+		query string
+		want  RecordFilter
+		ok    bool
+	}{
+		{"", RecordFilter{}, true},
+		{"from=2026-10-01&to=2026-10-07&min=60&max=90&level=一等奖&phone=1234", RecordFilter{
+			From: time.Date(2026, 10, 1, 0, 0, 0, 0, loc), To: time.Date(2026, 10, 7, 0, 0, 0, 0, loc),
+			MinScore: 60, MaxScore: 90, Level: "一等奖", PhoneSuffix: "1234",
+		}, true},
+		{"level=-&phone=+88+", RecordFilter{Level: "-", PhoneSuffix: "88"}, true},
+		{"from=10/01", RecordFilter{}, false},
+		{"to=2026-13-01", RecordFilter{}, false},
+		{"min=abc", RecordFilter{}, false},
+		{"max=9x", RecordFilter{}, false},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		got, err := parseRecordFilter(q)
+		if (err == nil) != tt.ok {
+			t.Fatalf("parseRecordFilter(%q) error = %v, want ok %v", tt.query, err, tt.ok)
+		}
+		if tt.ok && (!got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) ||
+			got.MinScore != tt.want.MinScore || got.MaxScore != tt.want.MaxScore || got.Level != tt.want.Level || got.PhoneSuffix != tt.want.PhoneSuffix) {
+			t.Fatalf("parseRecordFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRecords(t *testing.T) {
+	mutex.Lock()
+	savedDir, savedFile, savedBackend := dataDir, resultsFile, storageBackend
+	dataDir, storageBackend = t.TempDir(), StorageJSONL
+	resultsFile = filepath.Join(dataDir, "records.jsonl")
+	invalidateRecordsLocked()
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, eventLocation) }
+	recs := []ResultRecord{
+		{AttemptID: "a", Time: day(1, 10), MaskPhone: "138****1234", Score: 10, Total: 10, Award: Award{Level: "一等奖"}},
+		{AttemptID: "b", Time: day(2, 23), MaskPhone: "138****5678", Score: 5, Total: 10},
+		{AttemptID: "c", Time: day(3, 0), MaskPhone: "139****1234", Score: 8, Total: 10, Award: Award{Level: "二等奖"}},
+		{AttemptID: "d", Time: day(3, 12), MaskPhone: "139****0000", Score: 6, Total: 10, Award: Award{Level: "二等奖"}},
+	}
+	for _, r := range recs {
+		if err := appendRecordLocked(r); err != nil {
+			mutex.Unlock()
+			t.Fatal(err)
+		}
+	}
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir, resultsFile, storageBackend = savedDir, savedFile, savedBackend
+		invalidateRecordsLocked()
+		mutex.Unlock()
+	})
+
+	tests := []struct {
+		name       string
+		f          RecordFilter
+		page, size int
+		total      int
+		ids        []string
+	}{
+		{"all newest first", RecordFilter{}, 1, 0, 4, []string{"d", "c", "b", "a"}},
+		{"second page", RecordFilter{}, 2, 3, 4, []string{"a"}},
+		{"date range includes the end day", RecordFilter{From: day(2, 0), To: day(2, 0)}, 1, 10, 1, []string{"b"}},
+		{"score range", RecordFilter{MinScore: 60, MaxScore: 80}, 1, 10, 2, []string{"d", "c"}},
+		{"no prize", RecordFilter{Level: "-"}, 1, 10, 1, []string{"b"}},
+		{"level", RecordFilter{Level: "二等奖"}, 1, 10, 2, []string{"d", "c"}},
+		{"phone suffix", RecordFilter{PhoneSuffix: "1234"}, 1, 10, 2, []string{"c", "a"}},
+		{"page past the end", RecordFilter{}, 3, 2, 4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := QueryRecords(tt.f, tt.page, tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, r := range p.Records {
+				ids = append(ids, r.AttemptID)
+			}
+			if p.Total != tt.total || len(ids) != len(tt.ids) {
+				t.Fatalf("QueryRecords() total %d, ids %v, want %d %v", p.Total, ids, tt.total, tt.ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.ids[i] {
+					t.Fatalf("QueryRecords() ids %v, want %v", ids, tt.ids)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ReportConfig 每日报表：到设定时间（活动时区）生成当天的汇总 Excel
+type ReportConfig struct {
+	Time    string     `json:"time,omitempty" toml:"time,omitempty"`         // 生成时间 HH:MM，为空时不生成
+	Dir     string     `json:"dir,omitempty" toml:"dir,omitempty"`           // 报表目录，为空时使用数据目录下的 reports
+	DropDir string     `json:"drop_dir,omitempty" toml:"drop_dir,omitempty"` // 另外复制一份到该目录（如网盘同步目录）
+	SMTP    SMTPConfig `json:"smtp" toml:"smtp"`
+}
+
+// SMTPConfig 通过 SMTP 发送报表，Addr 为空时不发送
+type SMTPConfig struct {
+	Addr     string   `json:"addr,omitempty" toml:"addr,omitempty"` // 邮件服务器，如 127.0.0.1:25
+	From     string   `json:"from,omitempty" toml:"from,omitempty"`
+	To       []string `json:"to,omitempty" toml:"to,omitempty"`
+	Username string   `json:"username,omitempty" toml:"username,omitempty"` // 需要登录时填写
+	Password string   `json:"password,omitempty" toml:"password,omitempty"`
+}
+
+// DailySummary 某一天的答题汇总
+type DailySummary struct {
+	Day          string
+	Attempts     int            // 答题次数
+	Participants int            // 参与人数（按手机号去重）
+	AverageScore float64        // 平均得分百分比
+	PassScore    int            // 及格线
+	Passed       int            // 达到及格线的答题次数
+	PassRate     float64        // 及格率 0~1
+	Prizes       map[string]int // 各奖品等级发放数量
+}
+
+var (
+	// reportConfig 每日报表设置，调用方需持有 mutex
+	reportConfig ReportConfig
+	// reportReschedule 报表设置变化后通知调度协程重新计算下次生成时间
+	reportReschedule    = make(chan struct{}, 1)
+	reportSchedulerOnce sync.Once
+)
+
+// validateReportConfig 检查报表设置
+func validateReportConfig(c ReportConfig) error {
+	if c.Time != "" {
+		if _, err := time.Parse("15:04", c.Time); err != nil {
+			return fmt.Errorf("报表生成时间 %q 格式应为 HH:MM", c.Time)
+		}
+	}
+	if c.SMTP.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.SMTP.Addr); err != nil {
+			return fmt.Errorf("邮件服务器地址 %q 无效，应为 主机:端口", c.SMTP.Addr)
+		}
+		if c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+			return fmt.Errorf("发送报表邮件需要填写发件人和收件人")
+		}
+	}
+	return nil
+}
+
+// setReportConfigLocked 替换报表设置并通知调度协程，调用方需持有 mutex
+func setReportConfigLocked(c ReportConfig) {
+	reportConfig = c
+	select {
+	case reportReschedule <- struct{}{}:
+	default:
+	}
+}
+
+// reportDirLocked 报表目录，调用方需持有 mutex
+func reportDirLocked() string {
+	if reportConfig.Dir != "" {
+		return reportConfig.Dir
+	}
+	return filepath.Join(dataDir, "reports")
+}
+
+// nextReportTime 下一次生成报表的时间，at 为空或无效时返回 false
+func nextReportTime(now time.Time, at string) (time.Time, bool) {
+	t, err := time.Parse("15:04", at)
+	if at == "" || err != nil {
+		return time.Time{}, false
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, true
+}
+
+// startReportScheduler 启动每日报表调度（只启动一次，服务重启后继续使用同一个协程）
+func startReportScheduler() {
+	reportSchedulerOnce.Do(func() { go runReportScheduler() })
+}
+
+// runReportScheduler 到设定时间生成当天报表，设置变化时重新计算下次时间
+func runReportScheduler() {
+	for {
+		mutex.Lock()
+		next, ok := nextReportTime(eventNow(), reportConfig.Time)
+		mutex.Unlock()
+
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if ok {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+			if path, err := runDailyReport(next); err != nil {
+				log.Printf("生成每日报表错误: %v", err)
+			} else {
+				log.Printf("每日报表已生成: %s", path)
+			}
+		case <-reportReschedule:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// runDailyReport 生成某天的报表，并按设置复制到投递目录、发送邮件。
+// 投递失败只记录日志，不影响报表本身。
+func runDailyReport(day time.Time) (string, error) {
+	path, sum, err := GenerateDailyReport(day)
+	if err != nil {
+		return "", err
+	}
+	mutex.Lock()
+	cfg := reportConfig
+	title := branding.Title
+	mutex.Unlock()
+
+	if cfg.DropDir != "" {
+		if err := copyReport(path, cfg.DropDir); err != nil {
+			log.Printf("复制每日报表到 %s 错误: %v", cfg.DropDir, err)
+		}
+	}
+	if cfg.SMTP.Addr != "" {
+		if err := sendReportMail(cfg.SMTP, title, path, sum); err != nil {
+			log.Printf("发送每日报表邮件错误: %v", err)
+		}
+	}
+	return path, nil
+}
+
+// summarizeDay 统计答题记录
+func summarizeDay(day string, recs []ResultRecord, pass int) DailySummary {
+	s := DailySummary{Day: day, PassScore: pass, Prizes: map[string]int{}}
+	people := map[string]bool{}
+	total := 0
+	for _, r := range recs {
+		s.Attempts++
+		key := r.Phone
+		if key == "" {
+			key = r.IdCard
+		}
+		if key == "" {
+			key = r.AttemptID
+		}
+		people[key] = true
+		p := recordPercentage(r)
+		total += p
+		if p >= pass {
+			s.Passed++
+		}
+		if r.Award.Level != "" {
+			s.Prizes[r.Award.Level]++
+		}
+	}
+	s.Participants = len(people)
+	if s.Attempts > 0 {
+		s.AverageScore = math.Round(float64(total)/float64(s.Attempts)*10) / 10
+		s.PassRate = float64(s.Passed) / float64(s.Attempts)
+	}
+	return s
+}
+
+// GenerateDailyReport 生成某天（活动时区）的报表：汇总、奖品发放和题目分析，返回文件路径
+func GenerateDailyReport(day time.Time) (string, DailySummary, error) {
+	mutex.Lock()
+	recs, err := recordsLocked()
+	qs := make([]Question, len(questions))
+	copy(qs, questions)
+	levels := make([]PrizeLevel, len(prizeLevels))
+	copy(levels, prizeLevels)
+	pass := passScore
+	dir := reportDirLocked()
+	loc := eventLocation
+	mutex.Unlock()
+	if err != nil {
+		return "", DailySummary{}, err
+	}
+
+	key := day.In(loc).Format("2006-01-02")
+	dayRecs := []ResultRecord{}
+	for _, r := range recs {
+		if !r.Time.IsZero() && r.Time.In(loc).Format("2006-01-02") == key {
+			dayRecs = append(dayRecs, r)
+		}
+	}
+	sum := summarizeDay(key, dayRecs, pass)
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := writeReportSheets(f, sum, levels, AnalyzeItems(qs, dayRecs)); err != nil {
+		return "", sum, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", sum, err
+	}
+	path := filepath.Join(dir, "report-"+key+".xlsx")
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", sum, err
+	}
+	if err := f.Write(out); err != nil {
+		out.Close()
+		return "", sum, err
+	}
+	if err := out.Close(); err != nil {
+		return "", sum, err
+	}
+	return path, sum, os.Rename(tmp, path)
+}
+
+// writeReportSheets 写入报表的三个工作表
+func writeReportSheets(f *excelize.File, sum DailySummary, levels []PrizeLevel, stats []ItemStat) error {
+	const summary, prizes, items = "汇总", "奖品发放", "题目分析"
+	if err := f.SetSheetName("Sheet1", summary); err != nil {
+		return err
+	}
+	rows := [][]interface{}{
+		{"日期", sum.Day},
+		{"答题次数", sum.Attempts},
+		{"参与人数", sum.Participants},
+		{"平均得分", fmt.Sprintf("%.1f%%", sum.AverageScore)},
+		{"及格线", fmt.Sprintf("%d%%", sum.PassScore)},
+		{"及格人次", sum.Passed},
+		{"及格率", fmt.Sprintf("%.1f%%", sum.PassRate*100)},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(summary, cell, &row); err != nil {
+			return err
+		}
+	}
+	_ = f.SetColWidth(summary, "A", "B", 16)
+
+	if _, err := f.NewSheet(prizes); err != nil {
+		return err
+	}
+	header := []interface{}{"奖品等级", "奖品", "发放数量"}
+	if err := f.SetSheetRow(prizes, "A1", &header); err != nil {
+		return err
+	}
+	r := 2
+	for _, l := range sortedPrizeLevels(sum.Prizes, levels) {
+		prize := ""
+		for _, pl := range levels {
+			if pl.Level == l {
+				prize = pl.Prize
+			}
+		}
+		row := []interface{}{l, prize, sum.Prizes[l]}
+		cell, _ := excelize.CoordinatesToCellName(1, r)
+		if err := f.SetSheetRow(prizes, cell, &row); err != nil {
+			return err
+		}
+		r++
+	}
+
+	if _, err := f.NewSheet(items); err != nil {
+		return err
+	}
+	return writeItemAnalysisSheet(f, items, stats)
+}
+
+// sortedPrizeLevels 按奖品规则的顺序列出等级，规则中已没有的等级按名称排在最后
+func sortedPrizeLevels(counts map[string]int, levels []PrizeLevel) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, l := range levels {
+		out = append(out, l.Level)
+		seen[l.Level] = true
+	}
+	rest := []string{}
+	for l := range counts {
+		if !seen[l] {
+			rest = append(rest, l)
+		}
+	}
+	sort.Strings(rest)
+	return append(out, rest...)
+}
+
+// copyReport 将报表复制到投递目录，先写临时文件再改名，避免监视目录的程序读到不完整的文件
+func copyReport(path, dir string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// sendReportMail 将报表作为附件发送，正文为汇总数据
+func sendReportMail(c SMTPConfig, title, path string, sum DailySummary) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%s 每日报表 %s", title, sum.Day)
+	text := fmt.Sprintf("日期: %s\r\n答题次数: %d\r\n参与人数: %d\r\n平均得分: %.1f%%\r\n及格率: %.1f%%（及格线 %d%%）\r\n",
+		sum.Day, sum.Attempts, sum.Participants, sum.AverageScore, sum.PassRate*100, sum.PassScore)
+	for _, l := range sortedPrizeLevels(sum.Prizes, nil) {
+		text += fmt.Sprintf("%s: %d\r\n", l, sum.Prizes[l])
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		c.From, strings.Join(c.To, ", "), mime.BEncoding.Encode("UTF-8", subject),
+		time.Now().Format(time.RFC1123Z), mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	writeBase64Lines(part, []byte(text))
+
+	name := filepath.Base(path)
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet; name=\"" + name + "\""},
+		"Content-Disposition":       {"attachment; filename=\"" + name + "\""},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	writeBase64Lines(part, data)
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if c.Username != "" {
+		host, _, _ := net.SplitHostPort(c.Addr)
+		auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+	return smtp.SendMail(c.Addr, auth, c.From, c.To, body.Bytes())
+}
+
+// writeBase64Lines 按邮件要求每行 76 个字符写出 base64 内容
+func writeBase64Lines(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		_, _ = w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	_, _ = w.Write([]byte(enc + "\r\n"))
+}
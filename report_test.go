@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestNextReportTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 18, 17, 30, 0, 0, loc)
+	tests := []struct {
+		at   string
+		want time.Time
+		ok   bool
+	}{
+		{"18:00", time.Date(2026, 10, 18, 18, 0, 0, 0, loc), true},
+		{"17:30", time.Date(2026, 10, 19, 17, 30, 0, 0, loc), true},
+		{"08:00", time.Date(2026, 10, 19, 8, 0, 0, 0, loc), true},
+		{"", time.Time{}, false},
+		{"25:00", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := nextReportTime(now, tt.at)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("nextReportTime(%q) = %v, %v, want %v, %v", tt.at, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSummarizeDay(t *testing.T) {
+	recs := []ResultRecord{
+		{AttemptID: "1", Phone: "p1", Score: 10, Total: 10, Award: Award{Level: "一等奖"}},
+		{AttemptID: "2", Phone: "p1", Score: 5, Total: 10},
+		{AttemptID: "3", IdCard: "i1", Score: 8, Total: 10, Award: Award{Level: "二等奖"}},
+		{AttemptID: "4", Score: 6, Total: 10, Award: Award{Level: "二等奖"}},
+	}
+	got := summarizeDay("2026-10-18", recs, 60)
+	want := DailySummary{
+		Day: "2026-10-18", Attempts: 4, Participants: 3, AverageScore: 72.5, PassScore: 60,
+		Passed: 3, PassRate: 0.75, Prizes: map[string]int{"一等奖": 1, "二等奖": 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("summarizeDay() = %+v, want %+v", got, want)
+	}
+	if empty := summarizeDay("2026-10-18", nil, 60); empty.Attempts != 0 || empty.PassRate != 0 {
+		t.Fatalf("summarizeDay(nil) = %+v", empty)
+	}
+}
+
+func TestSortedPrizeLevels(t *testing.T) {
+	levels := []PrizeLevel{{Level: "一等奖"}, {Level: "二等奖"}}
+	got := sortedPrizeLevels(map[string]int{"二等奖": 1, "旧等级B": 1, "旧等级A": 2}, levels)
+	if want := []string{"一等奖", "二等奖", "旧等级A", "旧等级B"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sortedPrizeLevels() = %v, want %v", got, want)
+	}
+}
+
+func TestRunDailyReport(t *testing.T) {
+	mutex.Lock()
+	savedDir, savedFile, savedReport := dataDir, resultsFile, reportConfig
+	dataDir = t.TempDir()
+	resultsFile = filepath.Join(dataDir, "records.xlsx")
+	drop := filepath.Join(t.TempDir(), "drop")
+	reportConfig = ReportConfig{DropDir: drop}
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir, resultsFile, reportConfig = savedDir, savedFile, savedReport
+		mutex.Unlock()
+	})
+
+	day := time.Date(2026, 10, 18, 20, 0, 0, 0, eventLocation)
+	path, err := runDailyReport(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "report-2026-10-18.xlsx" {
+		t.Fatalf("report path = %s", path)
+	}
+	if _, err := os.Stat(filepath.Join(drop, filepath.Base(path))); err != nil {
+		t.Fatalf("report not copied to drop dir: %v", err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, want := f.GetSheetList(), []string{"汇总", "奖品发放", "题目分析"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sheets = %v, want %v", got, want)
+	}
+	if v, _ := f.GetCellValue("汇总", "B1"); v != "2026-10-18" {
+		t.Fatalf("report day = %q", v)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 结果存储后端
+const (
+	StorageXlsx  = "xlsx"  // Excel 文件（默认），方便直接打开查看
+	StorageJSONL = "jsonl" // 每行一条 JSON 记录，追加写入，适合答题人数较多的活动
+)
+
+// ResultStore 答题结果存储
+type ResultStore interface {
+	// Append 追加一条答题记录
+	Append(rec ResultRecord) error
+	// Records 读取全部答题记录
+	Records() ([]ResultRecord, error)
+	// Location 存储位置，用于界面显示
+	Location() string
+	// Flush 将已写入的记录同步到磁盘，停止服务时调用
+	Flush() error
+}
+
+// xlsxStore 保存到 Excel 的结果存储
+type xlsxStore struct {
+	path string
+}
+
+func (s xlsxStore) Append(rec ResultRecord) error { return SaveResultToExcel(s.path, rec) }
+
+func (s xlsxStore) Records() ([]ResultRecord, error) { return LoadResultsFromExcel(s.path) }
+
+func (s xlsxStore) Location() string { return s.path }
+
+func (s xlsxStore) Flush() error { return syncFile(s.path) }
+
+// jsonlStore 保存到 JSON Lines 文件的结果存储
+type jsonlStore struct {
+	path string
+}
+
+// jsonlRecord JSON Lines 中的一行
+type jsonlRecord struct {
+	AttemptID  string          `json:"attempt_id"`
+	Time       time.Time       `json:"timestamp"`
+	Name       string          `json:"name"`
+	Phone      string          `json:"phone"`
+	IdCard     string          `json:"idCard"`
+	MaskName   string          `json:"maskName"`
+	MaskPhone  string          `json:"maskPhone"`
+	MaskIdCard string          `json:"maskIdCard"`
+	Score      int             `json:"score"`
+	Total      int             `json:"total"`
+	Award      Award           `json:"award"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+}
+
+func (s jsonlStore) Append(rec ResultRecord) error {
+	if dir := filepath.Dir(s.path); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	detail, err := json.Marshal(rec.Detail)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(jsonlRecord{
+		AttemptID:  rec.AttemptID,
+		Time:       rec.Time,
+		Name:       rec.Name,
+		Phone:      rec.Phone,
+		IdCard:     rec.IdCard,
+		MaskName:   rec.MaskName,
+		MaskPhone:  rec.MaskPhone,
+		MaskIdCard: rec.MaskIdCard,
+		Score:      rec.Score,
+		Total:      rec.Total,
+		Award:      rec.Award,
+		Detail:     detail,
+	})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s jsonlStore) Records() ([]ResultRecord, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := []ResultRecord{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var r jsonlRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			continue // 跳过写到一半的行
+		}
+		rec := ResultRecord{
+			AttemptID:  r.AttemptID,
+			Time:       r.Time,
+			Name:       r.Name,
+			Phone:      r.Phone,
+			IdCard:     r.IdCard,
+			MaskName:   r.MaskName,
+			MaskPhone:  r.MaskPhone,
+			MaskIdCard: r.MaskIdCard,
+			Score:      r.Score,
+			Total:      r.Total,
+			Award:      r.Award,
+		}
+		if len(r.Detail) > 0 {
+			rec.Detail = r.Detail
+		}
+		out = append(out, rec)
+	}
+	return out, sc.Err()
+}
+
+func (s jsonlStore) Location() string { return s.path }
+
+func (s jsonlStore) Flush() error { return syncFile(s.path) }
+
+// syncFile 将文件内容同步到磁盘，文件不存在时忽略
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var (
+	// storageBackend 结果存储后端，调用方需持有 mutex
+	storageBackend = StorageXlsx
+	// eventLocation 活动所在时区，用于判断"今天"和记录时间，调用方需持有 mutex
+	eventLocation = time.Local
+)
+
+// resultFileLocked 结果文件位置，未设置时使用数据目录下的 records.xlsx / records.jsonl，调用方需持有 mutex
+func resultFileLocked() string {
+	if resultsFile != "" {
+		return resultsFile
+	}
+	return filepath.Join(dataDir, "records."+storageBackend)
+}
+
+// resultStoreLocked 当前的结果存储，调用方需持有 mutex
+func resultStoreLocked() ResultStore {
+	if storageBackend == StorageJSONL {
+		return timedStore{jsonlStore{path: resultFileLocked()}, StorageJSONL}
+	}
+	return timedStore{xlsxStore{path: resultFileLocked()}, StorageXlsx}
+}
+
+// recordCache 已读取的答题记录，避免每次查询都重新读取结果文件，调用方需持有 mutex
+var (
+	recordCache     []ResultRecord
+	recordCacheFrom string // 缓存对应的存储方式和位置
+)
+
+// recordsLocked 当前结果存储中的全部答题记录，首次使用时从文件读取，之后随提交追加，
+// 返回的切片只读，调用方需持有 mutex
+func recordsLocked() ([]ResultRecord, error) {
+	store := resultStoreLocked()
+	key := storageBackend + ":" + store.Location()
+	if recordCache != nil && recordCacheFrom == key {
+		return recordCache, nil
+	}
+	recs, err := store.Records()
+	if err != nil {
+		return nil, err
+	}
+	if recs == nil {
+		recs = []ResultRecord{}
+	}
+	recordCache, recordCacheFrom = recs, key
+	return recs, nil
+}
+
+// appendRecordLocked 保存一条答题记录并加入缓存，调用方需持有 mutex
+func appendRecordLocked(rec ResultRecord) error {
+	if _, err := recordsLocked(); err != nil {
+		return err
+	}
+	if err := resultStoreLocked().Append(rec); err != nil {
+		return err
+	}
+	recordCache = append(recordCache, rec)
+	return nil
+}
+
+// invalidateRecordsLocked 结果文件变化后丢弃缓存，下次使用时重新读取，调用方需持有 mutex
+func invalidateRecordsLocked() {
+	recordCache, recordCacheFrom = nil, ""
+}
+
+// eventNow 活动时区的当前时间，调用方需持有 mutex
+func eventNow() time.Time {
+	return time.Now().In(eventLocation)
+}
+
+// sameEventDay 记录时间是否为活动时区的今天，调用方需持有 mutex
+func sameEventDay(t time.Time) bool {
+	return !t.IsZero() && t.In(eventLocation).Format("2006-01-02") == eventNow().Format("2006-01-02")
+}
+
+// countAttemptsToday 统计今天手机号或身份证哈希匹配的答题次数，调用方需持有 mutex
+func countAttemptsToday(recs []ResultRecord, phoneHash, idHash string) int {
+	n := 0
+	for _, r := range recs {
+		if sameEventDay(r.Time) && (r.Phone == phoneHash || r.IdCard == idHash) {
+			n++
+		}
+	}
+	return n
+}
+
+// participationLimitReachedLocked 按参与限制判断该用户今天是否还能答题，调用方需持有 mutex
+func participationLimitReachedLocked(phoneHash, idHash string) (bool, error) {
+	if participation.Policy == ParticipationUnlimited {
+		return false, nil
+	}
+	recs, err := recordsLocked()
+	if err != nil {
+		return false, err
+	}
+	max := participation.MaxPerDay
+	if max <= 0 {
+		max = 1
+	}
+	return countAttemptsToday(recs, phoneHash, idHash) >= max, nil
+}
+
+// codesUsedToday 今天已经发出的兑换码，调用方需持有 mutex
+func codesUsedToday(recs []ResultRecord) map[string]bool {
+	used := map[string]bool{}
+	for _, r := range recs {
+		if r.Award.Code != "" && sameEventDay(r.Time) {
+			used[r.Award.Code] = true
+		}
+	}
+	return used
+}
+
+// prizesIssuedToday 统计今天各奖品等级已发放的数量，调用方需持有 mutex
+func prizesIssuedToday(recs []ResultRecord) map[string]int {
+	issued := map[string]int{}
+	for _, r := range recs {
+		if r.Award.Level != "" && sameEventDay(r.Time) {
+			issued[r.Award.Level]++
+		}
+	}
+	return issued
+}
+
+// findAttempt 按答题编号查找记录
+func findAttempt(recs []ResultRecord, id string) (ResultRecord, bool) {
+	for _, r := range recs {
+		if r.AttemptID == id {
+			return r, true
+		}
+	}
+	return ResultRecord{}, false
+}
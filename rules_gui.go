@@ -0,0 +1,192 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// 界面上显示的奖品类型与发完处理方式
+var (
+	prizeKindNames = map[string]string{
+		PrizeKindCode:        "兑换码",
+		PrizeKindItem:        "实物",
+		PrizeKindConsolation: "参与奖",
+	}
+	fallbackNames = map[string]string{
+		FallbackDowngrade:   "降级发放",
+		FallbackConsolation: "改发参与奖",
+		FallbackNone:        "不发奖",
+	}
+)
+
+// optionKey 根据显示名称反查取值
+func optionKey(names map[string]string, label string) string {
+	for k, v := range names {
+		if v == label {
+			return k
+		}
+	}
+	return ""
+}
+
+// optionLabels 按固定顺序返回显示名称
+func optionLabels(names map[string]string, keys ...string) []string {
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, names[k])
+	}
+	return out
+}
+
+// prizeRuleRow 奖品规则表单中的一行
+type prizeRuleRow struct {
+	level    *widget.Entry
+	score    *widget.Entry
+	kind     *widget.Select
+	stock    *widget.Entry
+	lowStock *widget.Entry
+	prize    *widget.Entry
+	fallback *widget.Select
+}
+
+func newPrizeRuleRow(l PrizeLevel) *prizeRuleRow {
+	r := &prizeRuleRow{
+		level:    widget.NewEntry(),
+		score:    widget.NewEntry(),
+		kind:     widget.NewSelect(optionLabels(prizeKindNames, PrizeKindCode, PrizeKindItem, PrizeKindConsolation), nil),
+		stock:    widget.NewEntry(),
+		lowStock: widget.NewEntry(),
+		prize:    widget.NewEntry(),
+		fallback: widget.NewSelect(optionLabels(fallbackNames, FallbackDowngrade, FallbackConsolation, FallbackNone), nil),
+	}
+	r.level.SetText(l.Level)
+	r.score.SetText(strconv.Itoa(l.Score))
+	r.stock.SetText(strconv.Itoa(l.Stock))
+	r.lowStock.SetText(strconv.Itoa(l.LowStock))
+	r.prize.SetText(l.Prize)
+	kind := l.Kind
+	if kind == "" {
+		kind = PrizeKindCode
+	}
+	r.kind.SetSelected(prizeKindNames[kind])
+	fallback := l.Fallback
+	if fallback == "" {
+		fallback = FallbackDowngrade
+	}
+	r.fallback.SetSelected(fallbackNames[fallback])
+	return r
+}
+
+// value 解析表单内容，数字格式错误时返回错误
+func (r *prizeRuleRow) value() (PrizeLevel, error) {
+	l := PrizeLevel{
+		Level:    strings.TrimSpace(r.level.Text),
+		Kind:     optionKey(prizeKindNames, r.kind.Selected),
+		Prize:    strings.TrimSpace(r.prize.Text),
+		Fallback: optionKey(fallbackNames, r.fallback.Selected),
+	}
+	for _, f := range []struct {
+		name string
+		text string
+		dst  *int
+	}{
+		{"所需分数", r.score.Text, &l.Score},
+		{"库存", r.stock.Text, &l.Stock},
+		{"预警阈值", r.lowStock.Text, &l.LowStock},
+	} {
+		text := strings.TrimSpace(f.text)
+		if text == "" {
+			continue
+		}
+		v, err := strconv.Atoi(text)
+		if err != nil {
+			return l, fmt.Errorf("奖品等级 %s 的%s不是整数", l.Level, f.name)
+		}
+		*f.dst = v
+	}
+	return l, nil
+}
+
+// showPrizeRulesWindow 查看和编辑奖品等级、所需分数、及格线和发完处理方式，保存后立即生效
+func showPrizeRulesWindow(a fyne.App, onSaved func()) {
+	win := a.NewWindow("奖品规则")
+	win.Resize(fyne.NewSize(980, 480))
+
+	mutex.Lock()
+	cfg := currentEventConfigLocked()
+	mutex.Unlock()
+
+	passEntry := widget.NewEntry()
+	passEntry.SetText(strconv.Itoa(cfg.PassScore))
+
+	rows := []*prizeRuleRow{}
+	grid := container.NewGridWithColumns(8)
+	var rebuild func()
+	rebuild = func() {
+		grid.RemoveAll()
+		for _, h := range []string{"等级", "所需分数(%)", "类型", "库存", "预警阈值", "奖品名称", "发完时", ""} {
+			grid.Add(widget.NewLabelWithStyle(h, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+		for i, r := range rows {
+			i := i
+			grid.Add(r.level)
+			grid.Add(r.score)
+			grid.Add(r.kind)
+			grid.Add(r.stock)
+			grid.Add(r.lowStock)
+			grid.Add(r.prize)
+			grid.Add(r.fallback)
+			grid.Add(widget.NewButton("删除", func() {
+				rows = append(rows[:i], rows[i+1:]...)
+				rebuild()
+			}))
+		}
+		grid.Refresh()
+	}
+	for _, l := range cfg.PrizeLevels {
+		rows = append(rows, newPrizeRuleRow(l))
+	}
+	rebuild()
+
+	btnAdd := widget.NewButton("添加等级", func() {
+		rows = append(rows, newPrizeRuleRow(PrizeLevel{}))
+		rebuild()
+	})
+	btnSave := widget.NewButton("保存并生效", func() {
+		pass, err := strconv.Atoi(strings.TrimSpace(passEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("及格线不是整数"), win)
+			return
+		}
+		levels := make([]PrizeLevel, 0, len(rows))
+		for _, r := range rows {
+			l, err := r.value()
+			if err != nil {
+				dialog.ShowError(err, win)
+				return
+			}
+			levels = append(levels, l)
+		}
+		if err := applyPrizeRules(levels, pass); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if onSaved != nil {
+			onSaved()
+		}
+		win.Close()
+	})
+
+	top := container.NewBorder(nil, nil, widget.NewLabel("及格线(%):"), nil, passEntry)
+	bottom := container.NewHBox(btnAdd, btnSave)
+	win.SetContent(container.NewBorder(top, bottom, nil, nil, container.NewVScroll(grid)))
+	win.Show()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 答题开放状态
+const (
+	QuizOpen       = "open"        // 正在开放
+	QuizPaused     = "paused"      // 管理员手动暂停
+	QuizNotStarted = "not_started" // 活动尚未开始
+	QuizClosed     = "closed"      // 不在今天的开放时间内
+	QuizEnded      = "ended"       // 活动已结束
+	QuizStopping   = "stopping"    // 服务即将停止，不再开始新的答题
+)
+
+// ScheduleConfig 答题开放时间，均按活动时区，留空表示不限
+type ScheduleConfig struct {
+	StartDate string `json:"start_date,omitempty" toml:"start_date,omitempty"` // 活动开始日期 YYYY-MM-DD（含）
+	EndDate   string `json:"end_date,omitempty" toml:"end_date,omitempty"`     // 活动结束日期 YYYY-MM-DD（含）
+	OpenTime  string `json:"open_time,omitempty" toml:"open_time,omitempty"`   // 每天开放时间 HH:MM
+	CloseTime string `json:"close_time,omitempty" toml:"close_time,omitempty"` // 每天结束时间 HH:MM
+}
+
+// QuizStatus 当前是否开放答题，Message 为给参与者看的说明
+type QuizStatus struct {
+	State   string `json:"state"`
+	Open    bool   `json:"open"`
+	Message string `json:"message"`
+	OpensAt string `json:"opens_at,omitempty"` // 下次开放时间（RFC3339），已结束或暂停时为空
+}
+
+// schedule 答题开放时间，调用方需持有 mutex
+var schedule ScheduleConfig
+
+// submitGrace 每天的开放时间或活动结束后仍接受提交的时间，供结束前开始答题的人交卷
+const submitGrace = 15 * time.Minute
+
+// validateSchedule 检查开放时间设置
+func validateSchedule(s ScheduleConfig) error {
+	var start, end time.Time
+	var err error
+	if s.StartDate != "" {
+		if start, err = time.Parse("2006-01-02", s.StartDate); err != nil {
+			return fmt.Errorf("活动开始日期 %q 格式应为 YYYY-MM-DD", s.StartDate)
+		}
+	}
+	if s.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", s.EndDate); err != nil {
+			return fmt.Errorf("活动结束日期 %q 格式应为 YYYY-MM-DD", s.EndDate)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return fmt.Errorf("活动结束日期不能早于开始日期")
+	}
+	// 未设置开放时间时从零点开放
+	openAt, _ := time.Parse("15:04", "00:00")
+	var closeAt time.Time
+	if s.OpenTime != "" {
+		if openAt, err = time.Parse("15:04", s.OpenTime); err != nil {
+			return fmt.Errorf("开放时间 %q 格式应为 HH:MM", s.OpenTime)
+		}
+	}
+	if s.CloseTime != "" {
+		if closeAt, err = time.Parse("15:04", s.CloseTime); err != nil {
+			return fmt.Errorf("结束时间 %q 格式应为 HH:MM", s.CloseTime)
+		}
+		if !closeAt.After(openAt) {
+			return fmt.Errorf("每天的结束时间应晚于开放时间")
+		}
+	}
+	return nil
+}
+
+// scheduleClock 某天的 hm 时刻，hm 为空时为当天零点
+func scheduleClock(day time.Time, hm string) time.Time {
+	h, m := 0, 0
+	if t, err := time.Parse("15:04", hm); hm != "" && err == nil {
+		h, m = t.Hour(), t.Minute()
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location())
+}
+
+// scheduleStatus 按开放时间计算 now 时刻的状态（不考虑手动暂停）
+func scheduleStatus(s ScheduleConfig, now time.Time) QuizStatus {
+	loc := now.Location()
+	today := now.Format("2006-01-02")
+	// 日期均为 YYYY-MM-DD，可以直接按字符串比较
+	if s.EndDate != "" && today > s.EndDate {
+		return QuizStatus{State: QuizEnded, Message: "本次活动已结束，感谢您的关注"}
+	}
+	if s.StartDate != "" && today < s.StartDate {
+		day, _ := time.ParseInLocation("2006-01-02", s.StartDate, loc)
+		at := scheduleClock(day, s.OpenTime)
+		return QuizStatus{
+			State:   QuizNotStarted,
+			Message: "活动尚未开始，将于 " + at.Format("2006-01-02 15:04") + " 开放答题",
+			OpensAt: at.Format(time.RFC3339),
+		}
+	}
+
+	open := scheduleClock(now, s.OpenTime)
+	if now.Before(open) {
+		return QuizStatus{
+			State:   QuizClosed,
+			Message: "今天的答题将于 " + open.Format("15:04") + " 开放",
+			OpensAt: open.Format(time.RFC3339),
+		}
+	}
+	if s.CloseTime != "" && !now.Before(scheduleClock(now, s.CloseTime)) {
+		if s.EndDate != "" && today >= s.EndDate {
+			return QuizStatus{State: QuizEnded, Message: "本次活动已结束，感谢您的关注"}
+		}
+		next := open.AddDate(0, 0, 1)
+		return QuizStatus{
+			State:   QuizClosed,
+			Message: "今天的答题已结束，明天 " + next.Format("15:04") + " 再来吧",
+			OpensAt: next.Format(time.RFC3339),
+		}
+	}
+	return QuizStatus{State: QuizOpen, Open: true, Message: "答题进行中"}
+}
+
+// quizStatusLocked 当前的答题状态：活动日期和开放时间之外为未开始/已结束，
+// 开放时间内管理员手动暂停时为暂停，调用方需持有 mutex
+func quizStatusLocked() QuizStatus {
+	st := scheduleStatus(schedule, eventNow())
+	if st.Open && !acceptingSubmissions {
+		return QuizStatus{State: QuizPaused, Message: "答题已暂停，请稍后再试"}
+	}
+	return st
+}
+
+// attemptStatusLocked 能否开始新的答题：除 quizStatusLocked 外，服务即将停止时也不再开始，
+// 但已开始答题的人仍可提交，调用方需持有 mutex
+func attemptStatusLocked() QuizStatus {
+	if serverDraining {
+		return QuizStatus{State: QuizStopping, Message: "服务即将停止，请稍后再试"}
+	}
+	return quizStatusLocked()
+}
+
+// attemptStatus 能否开始新的答题
+func attemptStatus() QuizStatus {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return attemptStatusLocked()
+}
+
+// rejectSubmitLocked 检查能否提交答卷：开放时间内开始答题（持有有效的答题令牌）的人，
+// 在服务即将停止时或开放时间结束后 submitGrace 内仍可提交；没有有效令牌时返回 403，
+// 管理员手动暂停或超过结束时间时返回 503，调用方需持有 mutex
+func rejectSubmitLocked(w http.ResponseWriter, token string, now time.Time) bool {
+	if !acceptingSubmissions {
+		writeError(w, "答题已暂停，请稍后再试", http.StatusServiceUnavailable)
+		return true
+	}
+	// 答题令牌有效期较长，开放时间结束已超过 submitGrace 时不再接受提交
+	local := now.In(eventLocation)
+	if st := scheduleStatus(schedule, local); !st.Open && !scheduleStatus(schedule, local.Add(-submitGrace)).Open {
+		return rejectStatus(w, st)
+	}
+	if !validAttemptTokenLocked(token, now) {
+		writeError(w, "答题已过期或无效，请重新开始答题", http.StatusForbidden)
+		return true
+	}
+	return false
+}
+
+// rejectNewAttemptLocked 不能开始新的答题时返回 503 和说明，调用方需持有 mutex
+func rejectNewAttemptLocked(w http.ResponseWriter) bool {
+	return rejectStatus(w, attemptStatusLocked())
+}
+
+// rejectStatus 未开放时返回 503 和说明
+func rejectStatus(w http.ResponseWriter, st QuizStatus) bool {
+	if st.Open {
+		return false
+	}
+	writeError(w, st.Message, http.StatusServiceUnavailable)
+	return true
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScheduleStatus(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	at := func(day, hm string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", day+" "+hm, loc)
+		return t
+	}
+	event := ScheduleConfig{StartDate: "2026-10-01", EndDate: "2026-10-07", OpenTime: "09:00", CloseTime: "17:30"}
+	tests := []struct {
+		name    string
+		s       ScheduleConfig
+		now     time.Time
+		state   string
+		opensAt string
+	}{
+		{"no schedule", ScheduleConfig{}, at("2026-10-03", "03:00"), QuizOpen, ""},
+		{"open", event, at("2026-10-03", "12:00"), QuizOpen, ""},
+		{"at open time", event, at("2026-10-03", "09:00"), QuizOpen, ""},
+		{"not started", event, at("2026-09-30", "12:00"), QuizNotStarted, "2026-10-01T09:00:00+08:00"},
+		{"before open", event, at("2026-10-03", "08:59"), QuizClosed, "2026-10-03T09:00:00+08:00"},
+		{"after close", event, at("2026-10-03", "17:30"), QuizClosed, "2026-10-04T09:00:00+08:00"},
+		{"after close on last day", event, at("2026-10-07", "18:00"), QuizEnded, ""},
+		{"open on last day", event, at("2026-10-07", "17:00"), QuizOpen, ""},
+		{"ended", event, at("2026-10-08", "12:00"), QuizEnded, ""},
+		{"end date only", ScheduleConfig{EndDate: "2026-10-07"}, at("2026-10-07", "23:59"), QuizOpen, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scheduleStatus(tt.s, tt.now)
+			if got.State != tt.state || got.OpensAt != tt.opensAt || got.Open != (tt.state == QuizOpen) {
+				t.Fatalf("scheduleStatus() = %+v, want state %s opens_at %q", got, tt.state, tt.opensAt)
+			}
+			if got.Message == "" {
+				t.Fatal("scheduleStatus() has no message")
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		s    ScheduleConfig
+		ok   bool
+	}{
+		{"empty", ScheduleConfig{}, true},
+		{"full", ScheduleConfig{StartDate: "2026-10-01", EndDate: "2026-10-07", OpenTime: "09:00", CloseTime: "17:30"}, true},
+		{"single day", ScheduleConfig{StartDate: "2026-10-01", EndDate: "2026-10-01"}, true},
+		{"close time only", ScheduleConfig{CloseTime: "20:00"}, true},
+		{"bad start date", ScheduleConfig{StartDate: "2026/10/01"}, false},
+		{"bad end date", ScheduleConfig{EndDate: "10-07"}, false},
+		{"end before start", ScheduleConfig{StartDate: "2026-10-07", EndDate: "2026-10-01"}, false},
+		{"bad open time", ScheduleConfig{OpenTime: "9点"}, false},
+		{"close before open", ScheduleConfig{OpenTime: "18:00", CloseTime: "09:00"}, false},
+		{"close equals open", ScheduleConfig{OpenTime: "09:00", CloseTime: "09:00"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSchedule(tt.s); (err == nil) != tt.ok {
+				t.Fatalf("validateSchedule() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRejectSubmitLocked(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	savedAccepting, savedDraining, savedSchedule := acceptingSubmissions, serverDraining, schedule
+	defer func() { acceptingSubmissions, serverDraining, schedule = savedAccepting, savedDraining, savedSchedule }()
+
+	now := time.Date(2026, 10, 18, 18, 10, 0, 0, eventLocation)
+	token, err := issueAttemptTokenLocked(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := issueAttemptTokenLocked(now.Add(-attemptTokenTTL - time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		delete(attemptTokens, token)
+		delete(attemptTokens, stale)
+	}()
+
+	tests := []struct {
+		name      string
+		accepting bool
+		draining  bool
+		schedule  ScheduleConfig
+		token     string
+		want      int // 0 表示可以提交
+	}{
+		{"valid token", true, false, ScheduleConfig{}, token, 0},
+		{"valid token while draining", true, true, ScheduleConfig{}, token, 0},
+		{"paused", false, false, ScheduleConfig{}, token, http.StatusServiceUnavailable},
+		{"expired token", true, false, ScheduleConfig{}, stale, http.StatusForbidden},
+		{"unknown token", true, false, ScheduleConfig{}, "0123", http.StatusForbidden},
+		{"no token", true, false, ScheduleConfig{}, "", http.StatusForbidden},
+		{"closed within grace", true, false, ScheduleConfig{OpenTime: "09:00", CloseTime: "18:00"}, token, 0},
+		{"closed past grace", true, false, ScheduleConfig{OpenTime: "09:00", CloseTime: "17:00"}, token, http.StatusServiceUnavailable},
+		{"last day closed within grace", true, false, ScheduleConfig{EndDate: "2026-10-18", CloseTime: "18:00"}, token, 0},
+		{"event ended", true, false, ScheduleConfig{EndDate: "2026-10-17"}, token, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acceptingSubmissions, serverDraining, schedule = tt.accepting, tt.draining, tt.schedule
+			w := httptest.NewRecorder()
+			rejected := rejectSubmitLocked(w, tt.token, now)
+			if rejected != (tt.want != 0) || (rejected && w.Code != tt.want) {
+				t.Fatalf("rejectSubmitLocked() = %v, status %d, want %d", rejected, w.Code, tt.want)
+			}
+		})
+	}
+}
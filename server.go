@@ -0,0 +1,932 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web/*
+var webFS embed.FS
+
+type Question struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Prompt  string   `json:"question"`
+	Options []string `json:"options"`
+	Answer  []int    `json:"answer"`
+	Score   int      `json:"score"`
+}
+
+// Web 服务运行状态
+const (
+	ServerStopped  = "stopped"
+	ServerRunning  = "running"
+	ServerStopping = "stopping" // 正在等待进行中的答题提交
+)
+
+const (
+	// drainPeriod 停止服务前不再开始新的答题、继续接收提交的时间
+	drainPeriod = 15 * time.Second
+	// shutdownTimeout 等待进行中的请求完成的最长时间
+	shutdownTimeout = 10 * time.Second
+)
+
+var (
+	// serverMu 保护以下 Web 服务状态。与 mutex 分开，停止服务等待请求完成时不会阻塞请求处理
+	serverMu     sync.Mutex
+	server       *http.Server
+	serverState  = ServerStopped
+	serverCancel context.CancelFunc
+	serverDone   chan struct{} // 当前服务完全停止后关闭
+
+	// serverDraining 服务即将停止，不再开始新的答题，调用方需持有 mutex
+	serverDraining bool
+)
+
+var (
+	mutex       sync.Mutex
+	questions   []Question
+	prizeLevels []PrizeLevel
+	prizeCodes  []PrizeCode
+	usedCodes   []string
+	resultsFile string
+	// listenAddr 配置的监听地址，调用方需持有 mutex
+	listenAddr = ":8080"
+	// listenFallback 端口被占用时是否自动换用后面的空闲端口，调用方需持有 mutex
+	listenFallback bool
+	// boundAddr 服务实际监听的地址，服务停止时为空，调用方需持有 mutex
+	boundAddr string
+	// baseURL 服务运行时的访问地址，服务停止时为空，调用方需持有 mutex
+	baseURL = ""
+	// advertiseHost 管理员选择的二维码地址（IP 或主机名），为空时自动检测，调用方需持有 mutex
+	advertiseHost string
+	dataDir       = "."
+)
+
+func localIP() string {
+	return getLocalIP()
+}
+
+// getLocalIP 获取本地IP地址：优先使用真实网卡的局域网地址，没有时使用其它网卡的地址。
+// 只枚举本机网卡，不访问网络，离线时也能立即返回，可以在持有 mutex 时调用
+func getLocalIP() string {
+	if ip := getIPFromInterfaces(); ip != "" {
+		return ip
+	}
+	if addrs := listNetworkAddresses(); len(addrs) > 0 {
+		return addrs[0].IP
+	}
+	return "127.0.0.1"
+}
+
+// getIPFromInterfaces 从网络接口获取IP，优先使用真实网卡的局域网地址，跳过虚拟机和容器的虚拟网卡
+func getIPFromInterfaces() string {
+	for _, a := range listNetworkAddresses() {
+		if a.Private && !a.Virtual {
+			return a.IP
+		}
+	}
+	return ""
+}
+
+// isValidLocalIP 检查是否是有效的局域网IP
+func isValidLocalIP(ip string) bool {
+	if ip == "127.0.0.1" || ip == "::1" || ip == "0.0.0.0" {
+		return false
+	}
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+
+	// 检查私有IP地址范围
+	privateRanges := []string{
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"169.254.0.0/16", // 链路本地地址
+	}
+
+	for _, cidr := range privateRanges {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(parsedIP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// effectiveListenAddrLocked 服务运行时为实际监听的地址，否则为配置的地址，调用方需持有 mutex
+func effectiveListenAddrLocked() string {
+	if boundAddr != "" {
+		return boundAddr
+	}
+	return listenAddr
+}
+
+// serverURL 根据监听地址生成访问地址，监听所有网卡时使用传入的本机 IP，调用方需持有 mutex
+func serverURL(ip string) string {
+	addr := effectiveListenAddrLocked()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return urlSchemeLocked() + "://" + ip + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = ip
+	}
+	return urlSchemeLocked() + "://" + net.JoinHostPort(host, port)
+}
+
+// getNetworkInfo 获取网络信息（主函数）
+func getNetworkInfo() string {
+	ip := getLocalIP()
+	// 如果获取不到有效IP，提供使用说明
+	if ip == "127.0.0.1" {
+		return "无法自动获取IP，请手动查看手机IP地址"
+	}
+
+	return ip
+}
+
+// setupWebHandlers mounts template endpoints and static resource handler (serves from embed)
+func setupWebHandlers(mux *http.ServeMux) {
+	// templates
+	tStart := template.Must(template.ParseFS(webFS, "web/start.html"))
+	tIdentity := template.Must(template.ParseFS(webFS, "web/identity.html"))
+	tQuiz := template.Must(template.ParseFS(webFS, "web/quiz.html"))
+	tReward := template.Must(template.ParseFS(webFS, "web/reward.html"))
+	tClosed := template.Must(template.ParseFS(webFS, "web/closed.html"))
+
+	// root -> start page
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = tStart.Execute(w, currentBranding())
+	})
+	// API: 获取网络信息
+	mux.HandleFunc("/api/network-info", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		mutex.Lock()
+		u := publicURLLocked()
+		manual := advertiseHost != ""
+		mutex.Unlock()
+		host := u
+		if p, err := url.Parse(u); err == nil {
+			host = p.Hostname()
+		}
+		info := map[string]string{
+			"ip":     host,
+			"url":    u,
+			"status": "ready",
+		}
+
+		if host == "127.0.0.1" && !manual {
+			info["message"] = "无法自动获取IP，请手动查看手机IP地址"
+			info["status"] = "manual_required"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+
+	// API: check-user 检查用户是否已经答题
+	mux.HandleFunc("/api/check-user", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		var req struct {
+			PhoneHash string `json:"phone_hash"`
+			IdHash    string `json:"id_hash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.PhoneHash == "" || req.IdHash == "" {
+			writeError(w, "user_hash is required", http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		answered, err := participationLimitReachedLocked(req.PhoneHash, req.IdHash)
+		if err != nil {
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{
+			"answered": answered,
+		})
+	})
+
+	mux.HandleFunc("/identity.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// 不在开放时间或已暂停时显示说明页
+		if st := attemptStatus(); !st.Open {
+			_ = tClosed.Execute(w, struct {
+				BrandingConfig
+				Status QuizStatus
+			}{currentBranding(), st})
+			return
+		}
+		_ = tIdentity.Execute(w, currentBranding())
+	})
+
+	// API: 当前是否开放答题
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(attemptStatus())
+	})
+	mux.HandleFunc("/quiz.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = tQuiz.Execute(w, currentBranding())
+	})
+	mux.HandleFunc("/reward.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = tReward.Execute(w, currentBranding())
+	})
+
+	// static resources served from embed at /static/
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		// strip /static/
+		path := strings.TrimPrefix(r.URL.Path, "/static/")
+		if path == "" {
+			http.NotFound(w, r)
+			return
+		}
+		// read from embed
+		b, err := webFS.ReadFile("web/static/" + path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		switch ext {
+		case ".css":
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		case ".js":
+			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		case ".png":
+			w.Header().Set("Content-Type", "image/png")
+		case ".jpg", ".jpeg":
+			w.Header().Set("Content-Type", "image/jpeg")
+		case ".html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		_, _ = w.Write(b)
+	})
+	// 网页管理后台
+	setupAdminHandlers(mux)
+
+	// 监控指标
+	setupMetricsHandlers(mux)
+
+	// 存活与就绪检查
+	setupHealthHandlers(mux)
+
+	// API: start-info
+	mux.HandleFunc("/api/start-info", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		u := publicURL()
+
+		qb64, _ := generateQRCodeBase64(u + "/identity.html")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"exam_url": u + "/identity.html",
+			"qrcode":   "data:image/png;base64," + qb64,
+		})
+	})
+	// API: questions (returns shuffled)
+	mux.HandleFunc("/api/questions", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		mutex.Lock()
+		if rejectNewAttemptLocked(w) {
+			mutex.Unlock()
+			return
+		}
+		token, err := issueAttemptTokenLocked(time.Now())
+		if err != nil {
+			mutex.Unlock()
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		arr := make([]Question, len(questions))
+		copy(arr, questions)
+		recordStartLocked()
+		mutex.Unlock()
+		countAttemptStarted()
+		// shuffle
+		for i := range arr {
+			j := int(time.Now().UnixNano()) % (len(arr) + 1)
+			if j >= len(arr) {
+				j = 0
+			}
+			arr[i], arr[j] = arr[j], arr[i]
+		}
+		w.Header().Set(attemptTokenHeader, token)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(arr)
+	})
+
+	// API: submit (新的奖品发放逻辑)
+	mux.HandleFunc("/api/submit", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			// Token 下发题目时返回的答题令牌
+			Token      string           `json:"token"`
+			Name       string           `json:"name"`
+			Phone      string           `json:"phone"`
+			IdCard     string           `json:"idCard"`
+			MaskName   string           `json:"mask_name"`
+			MaskPhone  string           `json:"mask_phone"`
+			MaskIdCard string           `json:"mask_idCard"`
+			Answers    map[string][]int `json:"answers"`
+			// Durations 每道题的停留时间（秒），用于题目分析
+			Durations map[string]float64 `json:"durations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" || len(req.Phone) != 64 || len(req.IdCard) != 64 {
+			writeError(w, "invalid info", http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// 开放时间结束前开始的答题仍可提交，不能开始新答题由 /api/questions 检查
+		if rejectSubmitLocked(w, req.Token, time.Now()) {
+			return
+		}
+
+		// 提交时再按参与限制检查一次，避免绕过身份页直接提交
+		if reached, err := participationLimitReachedLocked(req.Phone, req.IdCard); err != nil {
+			log.Printf("检查参与限制错误: %v", err)
+		} else if reached {
+			writeError(w, "今天的答题次数已用完", http.StatusConflict)
+			return
+		}
+
+		total := 0
+		score := 0
+		detail := map[string]interface{}{}
+		for _, q := range questions {
+			s := 1
+			if q.Score > 0 {
+				s = q.Score
+			}
+			total += s
+			given := req.Answers[q.ID]
+			gotScore, ok := gradeQuestion(q, given)
+			if ok {
+				score += gotScore
+			}
+			givenLabels := []string{}
+			for _, gi := range given {
+				if gi >= 0 && gi < len(q.Options) {
+					givenLabels = append(givenLabels, q.Options[gi])
+				}
+			}
+			d := map[string]interface{}{"given": givenLabels, "correct": gotScore > 0}
+			if sec := req.Durations[q.ID]; sec > 0 && sec < 24*3600 {
+				d["seconds"] = math.Round(sec*10) / 10
+			}
+			detail[q.ID] = d
+		}
+
+		// 新的奖品发放逻辑
+		var award Award
+		percentage := 0
+		if total > 0 {
+			// 计算百分比分数
+			percentage = int(float64(score) / float64(total) * 100)
+		}
+		if total > 0 && len(prizeLevels) > 0 {
+			award = awardPrizeLocked(percentage)
+		}
+
+		rec := ResultRecord{
+			AttemptID:  newAttemptID(),
+			Time:       eventNow(),
+			Name:       req.Name,
+			Phone:      req.Phone,
+			IdCard:     req.IdCard,
+			MaskName:   req.MaskName,
+			MaskPhone:  req.MaskPhone,
+			MaskIdCard: req.MaskIdCard,
+			Score:      score,
+			Total:      total,
+			Award:      award,
+			Detail:     detail,
+		}
+		if err := appendRecordLocked(rec); err != nil {
+			// 结果未保存时不能发放凭证：重启后无法核验和兑奖，奖品退回，令牌保留以便重新提交
+			log.Printf("保存答题结果错误: %v", err)
+			releaseAwardLocked(award)
+			writeError(w, "保存答题结果失败，请联系现场工作人员", http.StatusInternalServerError)
+			return
+		}
+		if award.Code != "" {
+			usedCodes = append(usedCodes, award.Code)
+		}
+		attempts[rec.AttemptID] = rec
+		setAccessAttempt(r, rec.AttemptID)
+		ip := clientIP(r)
+		auditLocked(AuditSubmit, "", ip, map[string]string{"attempt_id": rec.AttemptID, "score": fmt.Sprintf("%d/%d", score, total)})
+		if award.Level != "" {
+			auditLocked(AuditPrize, "", ip, map[string]string{
+				"attempt_id": rec.AttemptID,
+				"level":      award.Level,
+				"kind":       award.Kind,
+				"code":       award.Code,
+				"prize":      award.Prize,
+			})
+		}
+		// 令牌只能使用一次，同一份答卷不能重复提交
+		delete(attemptTokens, req.Token)
+		recordSubmissionLocked(rec)
+		countSubmission(award)
+
+		// 奖励页面凭答题编号向服务器查询结果，避免通过修改链接伪造中奖页面
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"attempt_id": rec.AttemptID,
+		})
+	})
+
+	// API: result 按答题编号查询结果（奖励页面使用）
+	mux.HandleFunc("/api/result", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			writeError(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		rec, ok, err := lookupAttemptLocked(id)
+		signVoucher := voucherEnabled
+		mutex.Unlock()
+		if err != nil {
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			writeError(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		resp := attemptResponse(rec)
+		voucher := ""
+		if signVoucher {
+			if voucher, err = voucherForRecord(rec); err != nil {
+				log.Printf("签发兑换凭证错误: %v", err)
+			}
+		}
+		if voucher != "" {
+			qb64, _ := generateQRCodeBase64(voucher)
+			resp["voucher"] = voucher
+			resp["voucher_qr"] = "data:image/png;base64," + qb64
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	// API: certificate 下载凭证/证书图片，format=png|pdf
+	mux.HandleFunc("/api/certificate", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			writeError(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		b, contentType, err := certificateForAttempt(id, format)
+		if err == errAttemptNotFound {
+			writeError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("生成凭证错误: %v", err)
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		ext := "png"
+		if contentType == "application/pdf" {
+			ext = "pdf"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.%s"`, id, ext))
+		_, _ = w.Write(b)
+	})
+
+	// API: voucher/verify 核验兑换凭证（兑奖员登录后扫码提交凭证内容），同时返回核销状态
+	mux.HandleFunc("/api/voucher/verify", requireRole(rolesRedeem, func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Voucher string `json:"voucher"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "bad request:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		key, err := loadVoucherKey()
+		if err != nil {
+			writeError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		p, err := VerifyVoucher(req.Voucher, key)
+		if err != nil {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "error": err.Error()})
+			return
+		}
+		resp := map[string]interface{}{"valid": true, "voucher": p}
+		if red, ok, err := redemptionOf(p.AttemptID); err == nil && ok {
+			resp["redemption"] = red
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func gradeQuestion(q Question, given []int) (int, bool) {
+	if q.Type == "single" || q.Type == "judge" {
+		if len(q.Answer) > 0 && len(given) == 1 && q.Answer[0] == given[0] {
+			if q.Score > 0 {
+				return q.Score, true
+			}
+			return 1, true
+		}
+		return 0, false
+	}
+	if len(q.Answer) != len(given) {
+		return 0, false
+	}
+	m := map[int]bool{}
+	for _, a := range q.Answer {
+		m[a] = true
+	}
+	for _, g := range given {
+		if !m[g] {
+			return 0, false
+		}
+	}
+	if q.Score > 0 {
+		return q.Score, true
+	}
+	return 1, true
+}
+
+// loadQuestionBank 加载题库 Excel 并替换当前题目
+func loadQuestionBank(path string) (int, error) {
+	qs, err := LoadQuestionsFromExcel(path)
+	if err != nil {
+		return 0, err
+	}
+	mutex.Lock()
+	questions = qs
+	questionBankPath = path
+	mutex.Unlock()
+
+	if err := saveCurrentEventConfig(); err != nil {
+		log.Printf("保存活动配置错误: %v", err)
+	}
+	return len(qs), nil
+}
+
+// loadPrizeCodes 加载兑换码 Excel，过滤今天已使用的兑换码并恢复今天已发放的实物数量，
+// 返回奖品等级数和可用兑换码数。keepRules 为 true 时保留当前奖品规则，只替换兑换码。
+func loadPrizeCodes(path string, keepRules bool) (int, int, error) {
+	levels, codes, err := LoadCodesFromExcel(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if levels, err = normalizePrizeLevels(levels); err != nil {
+		return 0, 0, err
+	}
+
+	mutex.Lock()
+	recs, err := recordsLocked()
+	if err != nil {
+		log.Printf("读取答题结果错误: %v", err)
+	}
+	// Filter out codes that have been used today
+	used := codesUsedToday(recs)
+	availableCodes := []PrizeCode{}
+	for _, code := range codes {
+		if !used[code.Code] {
+			availableCodes = append(availableCodes, code)
+		}
+	}
+	if keepRules {
+		levels = prizeLevels
+	} else {
+		prizeLevels = levels
+	}
+	prizeCodes = availableCodes
+	codesPath = path
+	itemIssued = restoreIssuedItems(recs)
+	resetInventoryAlerts()
+	if onInventoryChange != nil {
+		onInventoryChange()
+	}
+	mutex.Unlock()
+
+	if err := saveCurrentEventConfig(); err != nil {
+		log.Printf("保存活动配置错误: %v", err)
+	}
+	return len(levels), len(availableCodes), nil
+}
+
+// resultPath 结果文件位置，未设置时使用数据目录下的 records.xlsx / records.jsonl
+func resultPath() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return resultStoreLocked().Location()
+}
+
+// setResultPath 设置结果文件位置，按扩展名选择存储方式（.jsonl 为 JSON Lines，其它为 Excel）
+func setResultPath(p string) {
+	mutex.Lock()
+	setResultPathLocked(p)
+	mutex.Unlock()
+
+	if err := saveCurrentEventConfig(); err != nil {
+		log.Printf("保存活动配置错误: %v", err)
+	}
+}
+
+// setResultPathLocked 同 setResultPath，但不保存活动配置，调用方需持有 mutex
+func setResultPathLocked(p string) {
+	resultsFile = p
+	if strings.EqualFold(filepath.Ext(p), ".jsonl") {
+		storageBackend = StorageJSONL
+	} else {
+		storageBackend = StorageXlsx
+	}
+	invalidateRecordsLocked()
+	resetDashboardLocked()
+}
+
+// portFallbackTries 端口被占用时最多尝试后面多少个端口
+const portFallbackTries = 20
+
+// listenWithFallback 监听 addr，失败且允许换用端口时依次尝试后面的端口
+func listenWithFallback(addr string, fallback bool) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err == nil || !fallback {
+		return ln, err
+	}
+	host, portStr, perr := net.SplitHostPort(addr)
+	port, aerr := strconv.Atoi(portStr)
+	if perr != nil || aerr != nil {
+		return nil, err
+	}
+	for p := port + 1; p <= port+portFallbackTries && p <= 65535; p++ {
+		if l, e := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p))); e == nil {
+			log.Printf("无法监听 %s（%v），已改用端口 %d", addr, err, p)
+			return l, nil
+		}
+	}
+	return nil, err
+}
+
+// setListenPort 修改监听端口（保留监听的网卡），下次启动服务时生效，并保存到活动配置
+func setListenPort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("端口应在 1~65535 之间")
+	}
+	mutex.Lock()
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		host = ""
+	}
+	listenAddr = net.JoinHostPort(host, strconv.Itoa(port))
+	mutex.Unlock()
+	return saveCurrentEventConfig()
+}
+
+// setListenFallback 设置端口被占用时是否自动换用其它端口，并保存到活动配置
+func setListenFallback(on bool) error {
+	mutex.Lock()
+	listenFallback = on
+	mutex.Unlock()
+	return saveCurrentEventConfig()
+}
+
+// startServer 启动 Web 服务并返回实际的访问地址，服务已在运行时直接返回当前地址。
+// 端口在返回前已经监听成功，监听失败时返回错误。onStopped 在服务完全停止后（在服务协程中）调用。
+func startServer(onStopped func()) (string, error) {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	if serverState != ServerStopped {
+		return publicURL(), nil
+	}
+
+	mutex.Lock()
+	addr, fallback, useTLS, mdns, captive := listenAddr, listenFallback, tlsEnabled, mdnsName, captivePortal
+	logs := logsDir()
+	mutex.Unlock()
+	var tlsConfig *tls.Config
+	if useTLS {
+		var err error
+		if tlsConfig, err = serverTLSConfig(); err != nil {
+			return "", err
+		}
+	}
+	ln, err := listenWithFallback(addr, fallback)
+	if err != nil {
+		return "", fmt.Errorf("无法监听 %s: %v", addr, err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	mux := http.NewServeMux()
+	// serve templates from embed and static via /static/
+	setupWebHandlers(mux)
+	// 本地根证书下载，仅在 HTTPS 模式下提供，未启用时不生成根证书
+	if tlsConfig != nil {
+		mux.HandleFunc("/ca.crt", serveCACertificate)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	var handler http.Handler = rateLimitHandler(sameOriginHandler(mux))
+	if captive {
+		if c, err := startCaptivePortal(port); err != nil {
+			log.Println(err)
+		} else {
+			captiveResponder = c
+			handler = captiveHandler(handler)
+			log.Println("已启用热点模式，请将热点的 DNS 服务器设为本机")
+		}
+	}
+	// 停止服务时取消所有请求的 context，用于结束看板推送等长连接
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:        ln.Addr().String(),
+		Handler:     accessLogHandler(logs, metricsHandler(mux, handler)),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	done := make(chan struct{})
+	server, serverCancel, serverDone = srv, cancel, done
+	serverState = ServerRunning
+	mutex.Lock()
+	serverDraining = false
+	boundAddr = ln.Addr().String()
+	tlsActive = tlsConfig != nil
+	baseURL = currentURLLocked()
+	u := baseURL
+	mutex.Unlock()
+	startReportScheduler()
+	if mdns != "" {
+		if m, err := startMDNS(mdns, port, tlsConfig != nil); err != nil {
+			log.Println(err)
+		} else {
+			mdnsResponder = m
+			log.Printf("已通过 mDNS 广播 %s.local", strings.ToLower(mdns))
+		}
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println("server error:", err)
+			// 服务异常退出：运行中时在此清理并关闭 done；stopServer 已在停止服务时由它清理，
+			// done 只在两者之一中关闭
+			serverMu.Lock()
+			owner := server == srv && serverState == ServerRunning
+			if owner {
+				cancel()
+				server, serverState = nil, ServerStopped
+				closeDiscoveryLocked()
+				mutex.Lock()
+				baseURL, boundAddr = "", ""
+				mutex.Unlock()
+			}
+			serverMu.Unlock()
+			if owner {
+				close(done)
+			}
+		}
+		<-done
+		if onStopped != nil {
+			onStopped()
+		}
+	}()
+	return u, nil
+}
+
+// serverStatus 当前的 Web 服务状态
+func serverStatus() string {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	return serverState
+}
+
+// closeDiscoveryLocked 关闭 mDNS 广播和热点模式，调用方需持有 serverMu
+func closeDiscoveryLocked() {
+	if mdnsResponder != nil {
+		mdnsResponder.close()
+		mdnsResponder = nil
+	}
+	if captiveResponder != nil {
+		captiveResponder.close()
+		captiveResponder = nil
+	}
+}
+
+// stopServer 平稳停止 Web 服务并阻塞到服务完全停止：先在 drain 时间内不再开始新的答题、
+// 继续接收已开始答题的提交，再等待进行中的请求完成（最多 shutdownTimeout），最后将结果同步到磁盘。
+// 服务已在停止中时等待其停止完成（drain 不变）
+func stopServer(drain time.Duration) {
+	serverMu.Lock()
+	switch serverState {
+	case ServerStopping:
+		done := serverDone
+		serverMu.Unlock()
+		<-done
+		return
+	case ServerStopped:
+		serverMu.Unlock()
+		return
+	}
+	srv, cancel, done := server, serverCancel, serverDone
+	serverState = ServerStopping
+	serverMu.Unlock()
+
+	mutex.Lock()
+	serverDraining = true
+	mutex.Unlock()
+	if drain > 0 {
+		log.Printf("已停止开始新的答题，%d 秒后停止服务", int(drain.Seconds()))
+		time.Sleep(drain)
+	}
+
+	cancel()
+	ctx, stop := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer stop()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("等待请求完成超时，强制停止服务: %v", err)
+		_ = srv.Close()
+	}
+
+	// 提交处理在持有 mutex 时写入结果，取得 mutex 后不会再有写到一半的记录
+	mutex.Lock()
+	if err := resultStoreLocked().Flush(); err != nil {
+		log.Printf("同步结果文件错误: %v", err)
+	}
+	serverDraining = false
+	baseURL, boundAddr = "", ""
+	mutex.Unlock()
+	accessLog.close()
+
+	serverMu.Lock()
+	closeDiscoveryLocked()
+	server, serverState = nil, ServerStopped
+	serverMu.Unlock()
+	close(done)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestListenWithFallback(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	addr := busy.Addr().String()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	if _, err := listenWithFallback(addr, false); err == nil {
+		t.Fatal("listenWithFallback() without fallback succeeded on a busy port")
+	}
+	ln, err := listenWithFallback(addr, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := ln.Addr().(*net.TCPAddr).Port
+	if got <= port || got > port+portFallbackTries {
+		t.Fatalf("fallback port = %d, want %d~%d", got, port+1, port+portFallbackTries)
+	}
+}
+
+// useTestServer 在临时数据目录中以本机随机端口运行服务，测试结束后恢复设置
+func useTestServer(t *testing.T) {
+	t.Helper()
+	mutex.Lock()
+	saved := currentEventConfigLocked()
+	savedDir := dataDir
+	dataDir = t.TempDir()
+	listenAddr, listenFallback = "127.0.0.1:0", false
+	tlsEnabled, mdnsName, captivePortal, advertiseHost = false, "", false, "127.0.0.1"
+	mutex.Unlock()
+	t.Cleanup(func() {
+		stopServer(0)
+		mutex.Lock()
+		listenAddr, listenFallback = saved.ListenAddr, saved.ListenFallback
+		tlsEnabled, mdnsName, captivePortal, advertiseHost = saved.TLS, saved.MDNSName, saved.CaptivePortal, saved.AdvertiseHost
+		dataDir = savedDir
+		mutex.Unlock()
+	})
+}
+
+func TestStopServerWaitsForDrain(t *testing.T) {
+	useTestServer(t)
+	stopped := make(chan struct{})
+	if _, err := startServer(func() { close(stopped) }); err != nil {
+		t.Fatal(err)
+	}
+	const drain = 300 * time.Millisecond
+	go stopServer(drain)
+	for serverStatus() != ServerStopping {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 平稳停止进行中时再次停止（如关闭窗口）应等到停止完成，而不是立即返回
+	start := time.Now()
+	stopServer(0)
+	if serverStatus() != ServerStopped {
+		t.Fatalf("serverStatus() = %s after stopServer returned", serverStatus())
+	}
+	if elapsed := time.Since(start); elapsed < drain/2 {
+		t.Fatalf("stopServer returned after %v, before the drain finished", elapsed)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("onStopped not called")
+	}
+	stopServer(0) // 已停止时直接返回
+}
+
+func TestStartServerReportsBoundURL(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	useTestServer(t)
+	mutex.Lock()
+	listenAddr, listenFallback = busy.Addr().String(), true
+	mutex.Unlock()
+
+	stopped := make(chan struct{})
+	u, err := startServer(func() { close(stopped) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	_, port, _ := net.SplitHostPort(boundAddr)
+	mutex.Unlock()
+	if want := "http://127.0.0.1:" + port; u != want || publicURL() != want {
+		t.Fatalf("startServer() = %q, publicURL() = %q, want %q", u, publicURL(), want)
+	}
+	if port == strconv.Itoa(busy.Addr().(*net.TCPAddr).Port) {
+		t.Fatalf("server bound to the busy port %s", port)
+	}
+	stopServer(0)
+	<-stopped
+	if u := publicURL(); u == "http://127.0.0.1:"+port {
+		t.Fatalf("publicURL() after stop = %q, still the bound address", u)
+	}
+}
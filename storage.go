@@ -14,8 +14,13 @@ import (
 
 // PrizeLevel represents prize level configuration
 type PrizeLevel struct {
-	Level string
-	Score int
+	Level    string `json:"level" toml:"level"`
+	Score    int    `json:"score" toml:"score"`
+	LowStock int    `json:"low_stock,omitempty" toml:"low_stock,omitempty"` // 低库存预警阈值，0 表示使用默认值
+	Kind     string `json:"kind" toml:"kind"`                               // 奖品类型: code / item / consolation
+	Stock    int    `json:"stock,omitempty" toml:"stock,omitempty"`         // 实物奖品库存（参与奖为 0 时不限量）
+	Prize    string `json:"prize,omitempty" toml:"prize,omitempty"`         // 奖品名称，如 "雨伞"、"参与证书"
+	Fallback string `json:"fallback,omitempty" toml:"fallback,omitempty"`   // 该等级发完时的处理: downgrade / consolation / none
 }
 
 // PrizeCode represents a prize code with its level
@@ -186,10 +191,31 @@ func LoadCodesFromExcel(path string) ([]PrizeLevel, []PrizeCode, error) {
 
 			if level != "" && scoreStr != "" {
 				if score, err := strconv.Atoi(scoreStr); err == nil {
-					prizeLevels = append(prizeLevels, PrizeLevel{
-						Level: level,
-						Score: score,
-					})
+					pl := PrizeLevel{
+						Level:    level,
+						Score:    score,
+						Kind:     PrizeKindCode,
+						Fallback: FallbackDowngrade,
+					}
+					// 第三列（可选）为低库存预警阈值
+					if len(r) >= 3 {
+						if v, err := strconv.Atoi(strings.TrimSpace(r[2])); err == nil && v > 0 {
+							pl.LowStock = v
+						}
+					}
+					// 第四~六列（可选）为奖品类型、库存、奖品名称
+					if len(r) >= 4 {
+						pl.Kind = parsePrizeKind(r[3])
+					}
+					if len(r) >= 5 {
+						if v, err := strconv.Atoi(strings.TrimSpace(r[4])); err == nil && v > 0 {
+							pl.Stock = v
+						}
+					}
+					if len(r) >= 6 {
+						pl.Prize = strings.TrimSpace(r[5])
+					}
+					prizeLevels = append(prizeLevels, pl)
 				}
 			}
 		}
@@ -241,43 +267,107 @@ func LoadResultPathFromExcel(path string) (string, error) {
 
 }
 
-// IsCodeUsedToday checks if a code has been used today
-func IsCodeUsedToday(path, code string) (bool, error) {
+// 结果文件 Sheet1 的列
+const (
+	colTimestamp = iota
+	colName
+	colPhone
+	colIdCard
+	colMaskName
+	colMaskPhone
+	colMaskIdCard
+	colScore
+	colTotal
+	colCode
+	colDetail
+	colPrizeLevel
+	colPrizeKind
+	colPrize
+	colAttemptID
+)
+
+// resultHeader 结果文件表头
+var resultHeader = []interface{}{"timestamp", "name", "phone", "idCard", "maskName", "maskPhone", "maskIdCard", "score", "total", "code", "detail", "prizeLevel", "prizeKind", "prize", "attemptId"}
+
+// ResultRecord 一次答题的结果记录
+type ResultRecord struct {
+	AttemptID  string
+	Time       time.Time
+	Name       string
+	Phone      string
+	IdCard     string
+	MaskName   string
+	MaskPhone  string
+	MaskIdCard string
+	Score      int
+	Total      int
+	Award      Award
+	Detail     interface{}
+}
+
+// parseResultRow 将结果文件中的一行转换为 ResultRecord，缺少的列保持零值
+func parseResultRow(r []string) ResultRecord {
+	cell := func(i int) string {
+		if i < len(r) {
+			return strings.TrimSpace(r[i])
+		}
+		return ""
+	}
+	rec := ResultRecord{
+		AttemptID:  cell(colAttemptID),
+		Name:       cell(colName),
+		Phone:      cell(colPhone),
+		IdCard:     cell(colIdCard),
+		MaskName:   cell(colMaskName),
+		MaskPhone:  cell(colMaskPhone),
+		MaskIdCard: cell(colMaskIdCard),
+		Award: Award{
+			Level: cell(colPrizeLevel),
+			Kind:  cell(colPrizeKind),
+			Code:  cell(colCode),
+			Prize: cell(colPrize),
+		},
+	}
+	rec.Time, _ = time.Parse(time.RFC3339, cell(colTimestamp))
+	rec.Score, _ = strconv.Atoi(cell(colScore))
+	rec.Total, _ = strconv.Atoi(cell(colTotal))
+	if d := cell(colDetail); d != "" {
+		rec.Detail = json.RawMessage(d)
+	}
+	return rec
+}
+
+// LoadResultsFromExcel 读取结果文件中的全部记录，文件不存在时返回空
+func LoadResultsFromExcel(path string) ([]ResultRecord, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return false, nil
+		return nil, nil
 	}
 	f, err := excelize.OpenFile(path)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	defer f.Close()
 
 	rows, err := f.GetRows("Sheet1")
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	today := time.Now().Format("2006-01-02")
-
+	out := make([]ResultRecord, 0, len(rows))
 	for i, r := range rows {
 		if i == 0 {
 			continue // skip header
 		}
-		if len(r) >= 7 {
-			recordCode := strings.TrimSpace(r[6])
-			timestamp := strings.TrimSpace(r[0])
-
-			if recordCode == code && strings.Contains(timestamp, today) {
-				return true, nil
-			}
+		if len(r) > colPhone {
+			out = append(out, parseResultRow(r))
 		}
 	}
-
-	return false, nil
+	return out, nil
 }
 
 // SaveResultToExcel append a row to path (create file if not exist)
 // 存储结果到excel中
-func SaveResultToExcel(path, name, phone, idCard, maskName, maskPhone, maskIdCard string, score, total int, code string, detail interface{}) error {
+func SaveResultToExcel(path string, rec ResultRecord) error {
 	dir := filepath.Dir(path)
 	if dir != "" {
 		_ = os.MkdirAll(dir, 0755)
@@ -296,12 +386,16 @@ func SaveResultToExcel(path, name, phone, idCard, maskName, maskPhone, maskIdCar
 	}
 	sheet := "Sheet1"
 	if newFile {
-		_ = f.SetSheetRow(sheet, "A1", &[]interface{}{"timestamp", "name", "phone", "idCard", "maskName", "maskPhone", "maskIdCard", "score", "total", "code", "detail"})
+		_ = f.SetSheetRow(sheet, "A1", &resultHeader)
 	}
 	rows, _ := f.GetRows(sheet)
 	rowIdx := len(rows) + 1
-	detailB, _ := json.Marshal(detail)
-	row := []interface{}{time.Now().Format(time.RFC3339), name, phone, idCard, maskName, maskPhone, maskIdCard, score, total, code, string(detailB)}
+	detailB, _ := json.Marshal(rec.Detail)
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	row := []interface{}{ts.Format(time.RFC3339), rec.Name, rec.Phone, rec.IdCard, rec.MaskName, rec.MaskPhone, rec.MaskIdCard, rec.Score, rec.Total, rec.Award.Code, string(detailB), rec.Award.Level, rec.Award.Kind, rec.Award.Prize, rec.AttemptID}
 	cell, _ := excelize.CoordinatesToCellName(1, rowIdx)
 	if err := f.SetSheetRow(sheet, cell, &row); err != nil {
 		return err
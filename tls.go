@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// caValidity 本地根证书有效期
+	caValidity = 10 * 365 * 24 * time.Hour
+	// serverCertValidity 服务器证书有效期（iOS 要求不超过 825 天）
+	serverCertValidity = 397 * 24 * time.Hour
+	// serverCertRenewBefore 服务器证书到期前多久重新签发
+	serverCertRenewBefore = 30 * 24 * time.Hour
+)
+
+var (
+	// tlsEnabled 是否以 HTTPS 提供服务（下次启动服务时生效），调用方需持有 mutex
+	tlsEnabled bool
+	// tlsActive 当前运行的服务是否为 HTTPS，调用方需持有 mutex
+	tlsActive bool
+)
+
+// tlsDir 证书保存目录
+func tlsDir() string {
+	return filepath.Join(dataDir, "tls")
+}
+
+// urlSchemeLocked 访问地址使用的协议，服务运行时以实际情况为准，调用方需持有 mutex
+func urlSchemeLocked() string {
+	on := tlsEnabled
+	if boundAddr != "" {
+		on = tlsActive
+	}
+	if on {
+		return "https"
+	}
+	return "http"
+}
+
+// setTLSEnabled 启用或关闭 HTTPS（下次启动服务时生效），并保存到活动配置
+func setTLSEnabled(on bool) error {
+	mutex.Lock()
+	tlsEnabled = on
+	mutex.Unlock()
+	return saveCurrentEventConfig()
+}
+
+// writePEM 以 PEM 格式保存证书或私钥
+func writePEM(path, typ string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), perm)
+}
+
+// readCertPair 读取 PEM 格式的证书和私钥
+func readCertPair(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	cb, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	kb, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	cblock, _ := pem.Decode(cb)
+	kblock, _ := pem.Decode(kb)
+	if cblock == nil || kblock == nil {
+		return nil, nil, fmt.Errorf("证书文件格式错误")
+	}
+	cert, err := x509.ParseCertificate(cblock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(kblock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// randomSerial 证书序列号
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+}
+
+// caPrivateRanges 本地根证书允许签发的 IP 地址范围：局域网、链路本地和本机地址
+var caPrivateRanges = []string{
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "127.0.0.0/8",
+	"fc00::/7", "fe80::/10", "::1/128",
+}
+
+// caNameConstraints 本地根证书的名称限制：只能为 .local、localhost、局域网地址和管理员选择的地址签发证书，
+// 根证书私钥即使被复制，也不能用来冒充手机上的其它网站
+func caNameConstraints(adv string) ([]string, []*net.IPNet) {
+	domains := []string{"local", "localhost"}
+	ranges := []*net.IPNet{}
+	for _, s := range caPrivateRanges {
+		_, n, _ := net.ParseCIDR(s)
+		ranges = append(ranges, n)
+	}
+	if adv == "" {
+		return domains, ranges
+	}
+	if ip := net.ParseIP(adv); ip != nil {
+		if !ipInRanges(ip, ranges) {
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	} else if !domainInList(adv, domains) {
+		domains = append(domains, strings.ToLower(adv))
+	}
+	return domains, ranges
+}
+
+// ipInRanges IP 地址是否在其中一个范围内
+func ipInRanges(ip net.IP, ranges []*net.IPNet) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainInList 主机名是否为其中一个域名或其子域名
+func domainInList(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// caPermits 根证书的名称限制是否允许为该地址签发证书
+func caPermits(ca *x509.Certificate, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return ipInRanges(ip, ca.PermittedIPRanges)
+	}
+	return domainInList(host, ca.PermittedDNSDomains)
+}
+
+// loadOrCreateCA 读取本地根证书，不存在、没有名称限制或不包含管理员选择的地址时重新生成并保存到数据目录的 tls 目录
+func loadOrCreateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	dir := tlsDir()
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	mutex.Lock()
+	adv := advertiseHost
+	mutex.Unlock()
+	old, oldKey, err := readCertPair(certPath, keyPath)
+	switch {
+	case err == nil && old.PermittedDNSDomainsCritical && (adv == "" || caPermits(old, adv)):
+		return old, oldKey, nil
+	case err == nil:
+		log.Printf("本地根证书没有名称限制或不包含地址 %s，已重新生成，手机需要重新安装根证书", adv)
+	case !os.IsNotExist(err):
+		return nil, nil, fmt.Errorf("读取根证书错误: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: currentBranding().Title + " 本地根证书", Organization: []string{currentBranding().Organization}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	tmpl.PermittedDNSDomains, tmpl.PermittedIPRanges = caNameConstraints(adv)
+	tmpl.PermittedDNSDomainsCritical = true
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", kder, 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// certCovers 证书是否包含全部地址且不会很快过期
+func certCovers(cert *x509.Certificate, hosts []string) bool {
+	if time.Until(cert.NotAfter) < serverCertRenewBefore {
+		return false
+	}
+	for _, h := range hosts {
+		if cert.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// serverCertificate 返回包含 hosts 的服务器证书，已保存的证书不包含这些地址、即将过期或不是当前根证书签发时重新签发。
+// 根证书名称限制之外的地址（如公网 IP）不写入证书
+func serverCertificate(hosts []string) (tls.Certificate, error) {
+	dir := tlsDir()
+	certPath, keyPath := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	ca, caKey, err := loadOrCreateCA()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	permitted := []string{}
+	for _, h := range hosts {
+		if caPermits(ca, h) {
+			permitted = append(permitted, h)
+		}
+	}
+	hosts = permitted
+	if cert, _, err := readCertPair(certPath, keyPath); err == nil && certCovers(cert, hosts) && cert.CheckSignatureFrom(ca) == nil {
+		return tls.LoadX509KeyPair(certPath, keyPath)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(serverCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", kder, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// certificateHosts 服务器证书需要包含的地址：管理员选择的地址、mDNS 主机名、本机所有 IPv4 地址和 localhost
+func certificateHosts() []string {
+	mutex.Lock()
+	adv, mdnsHost := advertiseHost, mdnsHostLocked()
+	mutex.Unlock()
+	hosts := []string{}
+	seen := map[string]bool{}
+	add := func(h string) {
+		if h != "" && !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+	add(adv)
+	add(mdnsHost)
+	add(getLocalIP())
+	for _, a := range listNetworkAddresses() {
+		add(a.IP)
+	}
+	add("localhost")
+	add("127.0.0.1")
+	return hosts
+}
+
+// serverTLSConfig 为当前地址准备 HTTPS 配置
+func serverTLSConfig() (*tls.Config, error) {
+	cert, err := serverCertificate(certificateHosts())
+	if err != nil {
+		return nil, fmt.Errorf("生成服务器证书错误: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// caCertificateDER 本地根证书（DER 格式，手机可以直接安装），不存在时生成
+func caCertificateDER() ([]byte, error) {
+	cert, _, err := loadOrCreateCA()
+	if err != nil {
+		return nil, err
+	}
+	return cert.Raw, nil
+}
+
+// serveCACertificate 下载本地根证书，安装并信任后浏览器不再提示证书不安全
+func serveCACertificate(w http.ResponseWriter, r *http.Request) {
+	der, err := caCertificateDER()
+	if err != nil {
+		writeError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="quiz-ca.crt"`)
+	_, _ = w.Write(der)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCACertificateRoute(t *testing.T) {
+	for _, useTLS := range []bool{false, true} {
+		name := "http"
+		if useTLS {
+			name = "https"
+		}
+		t.Run(name, func(t *testing.T) {
+			useTestServer(t)
+			mutex.Lock()
+			tlsEnabled = useTLS
+			dir := tlsDir()
+			mutex.Unlock()
+			u, err := startServer(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(u, name+"://") {
+				t.Fatalf("startServer() = %q, want %s", u, name)
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+			resp, err := client.Get(u + "/ca.crt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			_, statErr := os.Stat(filepath.Join(dir, "ca.pem"))
+			isCert := resp.Header.Get("Content-Type") == "application/x-x509-ca-cert"
+			if !useTLS {
+				// 未启用 HTTPS 时由首页兜底，不下载也不生成根证书
+				if isCert || !os.IsNotExist(statErr) {
+					t.Fatalf("/ca.crt without TLS served a certificate %v, CA file error %v", isCert, statErr)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusOK || !isCert {
+				t.Fatalf("/ca.crt = %d %s, want the CA certificate", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+			if ca, err := x509.ParseCertificate(der); err != nil || !ca.IsCA {
+				t.Fatalf("/ca.crt is not a CA certificate: %v", err)
+			}
+		})
+	}
+}
+
+func TestCANameConstraints(t *testing.T) {
+	tests := []struct {
+		adv     string
+		permits []string
+		denies  []string
+	}{
+		{"", []string{"192.168.1.5", "10.0.0.1", "127.0.0.1", "::1", "quiz.local", "localhost"}, []string{"8.8.8.8", "example.com", "local.example.com"}},
+		{"192.168.3.7", []string{"192.168.3.7", "quiz.local"}, []string{"8.8.8.8"}},
+		{"203.0.113.5", []string{"203.0.113.5", "192.168.1.5"}, []string{"203.0.113.6", "8.8.8.8"}},
+		{"Quiz.Example.COM", []string{"quiz.example.com", "a.quiz.example.com", "quiz.local"}, []string{"example.com", "evil.com"}},
+		{"quiz.local", []string{"quiz.local"}, []string{"example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.adv, func(t *testing.T) {
+			ca := &x509.Certificate{}
+			ca.PermittedDNSDomains, ca.PermittedIPRanges = caNameConstraints(tt.adv)
+			for _, h := range tt.permits {
+				if !caPermits(ca, h) {
+					t.Errorf("caNameConstraints(%q) does not permit %s", tt.adv, h)
+				}
+			}
+			for _, h := range tt.denies {
+				if caPermits(ca, h) {
+					t.Errorf("caNameConstraints(%q) permits %s", tt.adv, h)
+				}
+			}
+		})
+	}
+}
+
+func TestServerCertificateWithinConstraints(t *testing.T) {
+	useTestServer(t)
+	mutex.Lock()
+	advertiseHost = "203.0.113.5"
+	mutex.Unlock()
+
+	cert, err := serverCertificate([]string{"203.0.113.5", "quiz.local", "192.168.1.5", "8.8.8.8", "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _, err := loadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, h := range []string{"203.0.113.5", "quiz.local", "192.168.1.5"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: h}); err != nil {
+			t.Errorf("server certificate for %s does not verify: %v", h, err)
+		}
+	}
+	// 名称限制之外的地址不写入证书
+	for _, ip := range leaf.IPAddresses {
+		if ip.Equal(net.ParseIP("8.8.8.8")) {
+			t.Error("server certificate includes 8.8.8.8")
+		}
+	}
+	for _, d := range leaf.DNSNames {
+		if d == "example.com" {
+			t.Error("server certificate includes example.com")
+		}
+	}
+
+	// 管理员改用根证书不包含的地址后重新生成根证书
+	mutex.Lock()
+	advertiseHost = "198.51.100.9"
+	mutex.Unlock()
+	ca2, _, err := loadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca2.Equal(ca) || !caPermits(ca2, "198.51.100.9") {
+		t.Fatal("loadOrCreateCA() did not regenerate the CA for the new address")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// voucherPrefix 凭证格式版本前缀
+const voucherPrefix = "QZV1"
+
+// errAlreadyRedeemed 凭证已经核销过
+var errAlreadyRedeemed = errors.New("该凭证已核销，不能重复兑奖")
+
+// invalidVoucherError 凭证本身无效（格式错误、签名无效或没有可兑换的奖品），用于与读写核销记录出错区分
+type invalidVoucherError struct{ err error }
+
+func (e invalidVoucherError) Error() string { return e.err.Error() }
+
+func (e invalidVoucherError) Unwrap() error { return e.err }
+
+var (
+	// voucherEnabled 是否为中奖结果签发兑换凭证（二维码），调用方需持有 mutex
+	voucherEnabled = true
+
+	voucherKeyOnce sync.Once
+	voucherKey     []byte
+	voucherKeyErr  error
+
+	// redemptions 已核销的凭证，按答题编号索引，首次使用时从数据目录下的 redeemed.jsonl 读取，调用方需持有 mutex
+	redemptions map[string]Redemption
+)
+
+// Redemption 一次兑奖核销记录
+type Redemption struct {
+	AttemptID string    `json:"attempt_id"`
+	Level     string    `json:"prize_level"`
+	Code      string    `json:"code,omitempty"`
+	By        string    `json:"by"`
+	Time      time.Time `json:"time"`
+}
+
+// VoucherPayload 凭证中携带的信息，核验时无需联网查询
+type VoucherPayload struct {
+	AttemptID string `json:"id"`
+	Level     string `json:"lvl,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Prize     string `json:"prize,omitempty"`
+	MaskName  string `json:"n,omitempty"`
+	Score     int    `json:"s"`
+	Total     int    `json:"t"`
+	IssuedAt  int64  `json:"ts"`
+}
+
+// loadVoucherKey 读取签名密钥，不存在时生成并保存到数据目录。
+// 核验凭证的电脑需要使用同一个 voucher.key。
+func loadVoucherKey() ([]byte, error) {
+	voucherKeyOnce.Do(func() {
+		path := filepath.Join(dataDir, "voucher.key")
+		if b, err := os.ReadFile(path); err == nil {
+			voucherKey, voucherKeyErr = hex.DecodeString(strings.TrimSpace(string(b)))
+			return
+		}
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			voucherKeyErr = err
+			return
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+			voucherKeyErr = err
+			return
+		}
+		voucherKey = key
+	})
+	return voucherKey, voucherKeyErr
+}
+
+// SignVoucher 生成 "QZV1.<payload>.<hmac>" 格式的凭证
+func SignVoucher(p VoucherPayload, key []byte) (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	body := voucherPrefix + "." + base64.RawURLEncoding.EncodeToString(b)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyVoucher 校验凭证签名并解析内容
+func VerifyVoucher(token string, key []byte) (VoucherPayload, error) {
+	var p VoucherPayload
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 || parts[0] != voucherPrefix {
+		return p, errors.New("凭证格式错误")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return p, errors.New("凭证格式错误")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return p, errors.New("凭证签名无效，可能被篡改")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return p, errors.New("凭证格式错误")
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("凭证内容错误: %v", err)
+	}
+	return p, nil
+}
+
+// voucherForRecord 为中奖记录签发凭证，未中奖时返回空串
+func voucherForRecord(rec ResultRecord) (string, error) {
+	if rec.Award.Level == "" {
+		return "", nil
+	}
+	return signRecord(rec)
+}
+
+// signRecord 为答题记录签发凭证（包括未中奖的参与证书）
+func signRecord(rec ResultRecord) (string, error) {
+	key, err := loadVoucherKey()
+	if err != nil {
+		return "", err
+	}
+	return SignVoucher(VoucherPayload{
+		AttemptID: rec.AttemptID,
+		Level:     rec.Award.Level,
+		Kind:      rec.Award.Kind,
+		Code:      rec.Award.Code,
+		Prize:     rec.Award.Prize,
+		MaskName:  rec.MaskName,
+		Score:     rec.Score,
+		Total:     rec.Total,
+		IssuedAt:  rec.Time.Unix(),
+	}, key)
+}
+
+// describeVoucher 凭证核验结果的展示文本
+func describeVoucher(p VoucherPayload) string {
+	lines := []string{
+		"答题编号: " + p.AttemptID,
+		"姓名: " + p.MaskName,
+		fmt.Sprintf("得分: %d / %d", p.Score, p.Total),
+		"奖品等级: " + p.Level,
+	}
+	if p.Code != "" {
+		lines = append(lines, "兑换码: "+p.Code)
+	}
+	if p.Prize != "" {
+		lines = append(lines, "奖品: "+p.Prize)
+	}
+	lines = append(lines, "签发时间: "+time.Unix(p.IssuedAt, 0).Format("2006-01-02 15:04:05"))
+	return strings.Join(lines, "\n")
+}
+
+// redemptionsPath 核销记录文件位置
+func redemptionsPath() string {
+	return filepath.Join(dataDir, "redeemed.jsonl")
+}
+
+// loadRedemptionsLocked 读取核销记录，调用方需持有 mutex
+func loadRedemptionsLocked() (map[string]Redemption, error) {
+	if redemptions != nil {
+		return redemptions, nil
+	}
+	b, err := os.ReadFile(redemptionsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	redemptions = map[string]Redemption{}
+	for _, line := range strings.Split(string(b), "\n") {
+		var r Redemption
+		if strings.TrimSpace(line) == "" || json.Unmarshal([]byte(line), &r) != nil {
+			continue
+		}
+		redemptions[r.AttemptID] = r
+	}
+	return redemptions, nil
+}
+
+// redemptionOf 查询某次答题的核销记录
+func redemptionOf(attemptID string) (Redemption, bool, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	m, err := loadRedemptionsLocked()
+	if err != nil {
+		return Redemption{}, false, err
+	}
+	r, ok := m[attemptID]
+	return r, ok, nil
+}
+
+// redeemVoucher 核验凭证并核销（兑奖），同一凭证只能核销一次，重复核销时返回 errAlreadyRedeemed 和原记录，
+// 凭证无效时返回 invalidVoucherError
+func redeemVoucher(token, by string) (VoucherPayload, Redemption, error) {
+	key, err := loadVoucherKey()
+	if err != nil {
+		return VoucherPayload{}, Redemption{}, err
+	}
+	p, err := VerifyVoucher(token, key)
+	if err != nil {
+		return p, Redemption{}, invalidVoucherError{err}
+	}
+	if p.Level == "" {
+		return p, Redemption{}, invalidVoucherError{errors.New("该凭证没有可兑换的奖品")}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	m, err := loadRedemptionsLocked()
+	if err != nil {
+		return p, Redemption{}, err
+	}
+	if r, ok := m[p.AttemptID]; ok {
+		return p, r, errAlreadyRedeemed
+	}
+	r := Redemption{AttemptID: p.AttemptID, Level: p.Level, Code: p.Code, By: by, Time: eventNow()}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return p, r, err
+	}
+	f, err := os.OpenFile(redemptionsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return p, r, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return p, r, err
+	}
+	if err := f.Close(); err != nil {
+		return p, r, err
+	}
+	m[r.AttemptID] = r
+	return p, r, nil
+}
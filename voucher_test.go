@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifyVoucher(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	p := VoucherPayload{AttemptID: "a1b2c3", Level: "一等奖", Kind: PrizeKindCode, Code: "AAAA-1111", Score: 9, Total: 10, IssuedAt: 1700000000}
+	token, err := SignVoucher(p, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"id":"a1b2c3","lvl":"特等奖","s":10,"t":10,"ts":1700000000}`))
+
+	tests := []struct {
+		name  string
+		token string
+		key   []byte
+		ok    bool
+	}{
+		{"valid", token, key, true},
+		{"surrounding whitespace", " " + token + "\n", key, true},
+		{"wrong key", token, []byte("another key"), false},
+		{"forged payload", parts[0] + "." + forged + "." + parts[2], key, false},
+		{"truncated signature", token[:len(token)-2], key, false},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!", key, false},
+		{"wrong prefix", "QZV0." + parts[1] + "." + parts[2], key, false},
+		{"missing part", parts[0] + "." + parts[1], key, false},
+		{"empty", "", key, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyVoucher(tt.token, tt.key)
+			if (err == nil) != tt.ok {
+				t.Fatalf("VerifyVoucher() error = %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && got != p {
+				t.Fatalf("VerifyVoucher() = %+v, want %+v", got, p)
+			}
+		})
+	}
+}
+
+func TestVoucherForRecord(t *testing.T) {
+	mutex.Lock()
+	savedDir := dataDir
+	dataDir = t.TempDir()
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir = savedDir
+		mutex.Unlock()
+	})
+	tests := []struct {
+		name  string
+		award Award
+		want  bool
+	}{
+		{"no prize", Award{}, false},
+		{"code prize", Award{Level: "一等奖", Kind: PrizeKindCode, Code: "C1"}, true},
+		{"item prize", Award{Level: "纪念品", Kind: PrizeKindItem, Prize: "雨伞"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := voucherForRecord(ResultRecord{AttemptID: "x1", Award: tt.award})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (v != "") != tt.want {
+				t.Fatalf("voucherForRecord() = %q, want voucher %v", v, tt.want)
+			}
+			if v == "" {
+				return
+			}
+			key, _ := loadVoucherKey()
+			p, err := VerifyVoucher(v, key)
+			if err != nil || p.AttemptID != "x1" || p.Level != tt.award.Level || p.Code != tt.award.Code {
+				t.Fatalf("VerifyVoucher() = %+v, %v", p, err)
+			}
+		})
+	}
+}
+
+func TestRedeemHandler(t *testing.T) {
+	mutex.Lock()
+	savedDir, savedRedemptions := dataDir, redemptions
+	dataDir, redemptions = t.TempDir(), nil
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		dataDir, redemptions = savedDir, savedRedemptions
+		mutex.Unlock()
+		auditTailDir = ""
+	})
+
+	winner, err := signRecord(ResultRecord{AttemptID: "r1", Award: Award{Level: "一等奖", Kind: PrizeKindCode, Code: "C1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noPrize, err := signRecord(ResultRecord{AttemptID: "r2", Score: 3, Total: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(winner, ".")
+
+	mux := http.NewServeMux()
+	setupAdminHandlers(mux)
+	clerk := useTestSession(t, RoleClerk)
+	viewer := useTestSession(t, RoleViewer)
+	tests := []struct {
+		name    string
+		cookie  *http.Cookie
+		voucher string
+		want    int
+	}{
+		{"redeem", clerk, winner, http.StatusOK},
+		{"already redeemed", clerk, winner, http.StatusConflict},
+		{"no prize", clerk, noPrize, http.StatusBadRequest},
+		{"bad signature", clerk, parts[0] + "." + parts[1] + ".AAAA", http.StatusBadRequest},
+		{"malformed", clerk, "not a voucher", http.StatusBadRequest},
+		{"viewer", viewer, winner, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"voucher": tt.voucher})
+			r := httptest.NewRequest(http.MethodPost, "/api/admin/redeem", strings.NewReader(string(body)))
+			r.AddCookie(tt.cookie)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			var resp struct {
+				Redeemed bool   `json:"redeemed"`
+				Error    string `json:"error"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&resp)
+			if w.Code != tt.want || resp.Redeemed != (tt.want == http.StatusOK) || (tt.want != http.StatusOK && resp.Error == "") {
+				t.Fatalf("/api/admin/redeem = %d %+v, want %d", w.Code, resp, tt.want)
+			}
+		})
+	}
+
+	red, ok, err := redemptionOf("r1")
+	if err != nil || !ok || red.By != "test-clerk" || red.Code != "C1" {
+		t.Fatalf("redemptionOf() = %+v, %v, %v", red, ok, err)
+	}
+}